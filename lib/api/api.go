@@ -0,0 +1,97 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Controller interface {
+	ListMaintenanceWindows(ctx context.Context, scope string, targetId string) ([]model.MaintenanceWindow, error)
+	GetMaintenanceWindow(ctx context.Context, id string) (model.MaintenanceWindow, bool, error)
+	SetMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) error
+	DeleteMaintenanceWindow(ctx context.Context, id string) error
+
+	ListNotificationOptOuts(ctx context.Context, userId string) ([]model.NotificationOptOut, error)
+	SetNotificationOptOut(ctx context.Context, optOut model.NotificationOptOut) error
+	DeleteNotificationOptOut(ctx context.Context, userId string, resourceId string) error
+
+	GetNotificationPreferences(ctx context.Context, userId string) (model.NotificationPreferences, bool, error)
+	SetNotificationPreferences(ctx context.Context, preferences model.NotificationPreferences) error
+	DeleteNotificationPreferences(ctx context.Context, userId string) error
+
+	ListNotificationAudit(ctx context.Context, query model.NotificationAuditQuery) ([]model.NotificationAuditEntry, error)
+}
+
+// Endpoints register their routes on the router
+var Endpoints = []func(router *http.ServeMux, config config.Config, control Controller){
+	MaintenanceWindowEndpoints,
+	NotificationOptOutEndpoints,
+	NotificationPreferenceEndpoints,
+	NotificationAuditEndpoints,
+}
+
+// Start serves the api on config.ApiPort until ctx is done; "" or "-" disables the api
+func Start(ctx context.Context, config config.Config, control Controller) error {
+	if config.ApiPort == "" || config.ApiPort == "-" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", ":"+config.ApiPort)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: GetRouter(config, control), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		log.Println("DEBUG: api shutdown", server.Shutdown(shutdownCtx))
+	}()
+	go func() {
+		log.Println("listening on ", listener.Addr())
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("FATAL:", err)
+		}
+	}()
+	return nil
+}
+
+func GetRouter(config config.Config, control Controller) http.Handler {
+	router := http.NewServeMux()
+	for _, endpoint := range Endpoints {
+		endpoint(router, config, control)
+	}
+	return router
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Println("ERROR: unable to encode response", err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultAuditLimit = 100
+
+// NotificationAuditEndpoints let admins query the notification decisions.
+// query parameters: device_id, owner, decision, from and to (RFC3339), limit (default 100) and offset.
+func NotificationAuditEndpoints(router *http.ServeMux, config config.Config, control Controller) {
+	if config.NotificationAuditCollection == "" || config.NotificationAuditCollection == "-" {
+		return
+	}
+
+	router.HandleFunc("GET /admin/notifications/audit", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseNotificationAuditQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := control.ListNotificationAudit(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, result)
+	}))
+}
+
+func parseNotificationAuditQuery(values url.Values) (query model.NotificationAuditQuery, err error) {
+	query = model.NotificationAuditQuery{
+		DeviceId: values.Get("device_id"),
+		Owner:    values.Get("owner"),
+		Decision: values.Get("decision"),
+		Limit:    defaultAuditLimit,
+	}
+	if value := values.Get("from"); value != "" {
+		query.From, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, errors.New("invalid from: expected RFC3339 time")
+		}
+	}
+	if value := values.Get("to"); value != "" {
+		query.To, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, errors.New("invalid to: expected RFC3339 time")
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit <= 0 {
+			return query, errors.New("invalid limit: expected positive integer")
+		}
+	}
+	if value := values.Get("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 {
+			return query, errors.New("invalid offset: expected non-negative integer")
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Token contains the claims used by the api.
+// the signature is not validated; tokens are expected to be validated by the api gateway.
+type Token struct {
+	Sub         string `json:"sub"`
+	RealmAccess struct {
+		Roles []string `json:"roles"`
+	} `json:"realm_access"`
+}
+
+func (this Token) IsAdmin() bool {
+	return slices.Contains(this.RealmAccess.Roles, "admin")
+}
+
+func GetToken(r *http.Request) (token Token, err error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return token, errors.New("missing bearer token")
+	}
+	parts := strings.Split(auth[len("Bearer "):], ".")
+	if len(parts) != 3 {
+		return token, errors.New("invalid token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal(payload, &token)
+	return token, err
+}
+
+// adminOnly responds with 401 for requests without token and 403 for requests of non admin users
+func adminOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := GetToken(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !token.IsAdmin() {
+			http.Error(w, "only admins may access this endpoint", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// authenticated responds with 401 for requests without token and passes the token to handler otherwise
+func authenticated(handler func(w http.ResponseWriter, r *http.Request, token Token)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := GetToken(r)
+		if err != nil || token.Sub == "" {
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r, token)
+	}
+}
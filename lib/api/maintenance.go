@@ -0,0 +1,102 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func MaintenanceWindowEndpoints(router *http.ServeMux, config config.Config, control Controller) {
+	if config.MaintenanceWindowCollection == "" || config.MaintenanceWindowCollection == "-" {
+		return
+	}
+
+	router.HandleFunc("GET /admin/maintenance-windows", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		result, err := control.ListMaintenanceWindows(r.Context(), r.URL.Query().Get("scope"), r.URL.Query().Get("target_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, result)
+	}))
+
+	router.HandleFunc("GET /admin/maintenance-windows/{id}", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		result, found, err := control.GetMaintenanceWindow(r.Context(), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "maintenance window not found", http.StatusNotFound)
+			return
+		}
+		writeJson(w, http.StatusOK, result)
+	}))
+
+	router.HandleFunc("POST /admin/maintenance-windows", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		window := model.MaintenanceWindow{}
+		err := json.NewDecoder(r.Body).Decode(&window)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		window.Id = uuid.NewString()
+		setMaintenanceWindow(w, r, control, window, http.StatusCreated)
+	}))
+
+	router.HandleFunc("PUT /admin/maintenance-windows/{id}", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		window := model.MaintenanceWindow{}
+		err := json.NewDecoder(r.Body).Decode(&window)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if window.Id != "" && window.Id != r.PathValue("id") {
+			http.Error(w, "id in body does not match id in path", http.StatusBadRequest)
+			return
+		}
+		window.Id = r.PathValue("id")
+		setMaintenanceWindow(w, r, control, window, http.StatusOK)
+	}))
+
+	router.HandleFunc("DELETE /admin/maintenance-windows/{id}", adminOnly(func(w http.ResponseWriter, r *http.Request) {
+		err := control.DeleteMaintenanceWindow(r.Context(), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func setMaintenanceWindow(w http.ResponseWriter, r *http.Request, control Controller, window model.MaintenanceWindow, status int) {
+	err := window.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = control.SetMaintenanceWindow(r.Context(), window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, status, window)
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"net/http"
+)
+
+// NotificationOptOutEndpoints let users manage their own notification opt-outs.
+// the resource id is a device id, a hub id or "*" for all devices and hubs.
+func NotificationOptOutEndpoints(router *http.ServeMux, config config.Config, control Controller) {
+	if config.NotificationOptOutCollection == "" || config.NotificationOptOutCollection == "-" {
+		return
+	}
+
+	router.HandleFunc("GET /notifications/opt-outs", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		result, err := control.ListNotificationOptOuts(r.Context(), token.Sub)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, result)
+	}))
+
+	router.HandleFunc("PUT /notifications/opt-outs/{resource_id}", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		optOut := model.NotificationOptOut{UserId: token.Sub, ResourceId: r.PathValue("resource_id")}
+		err := control.SetNotificationOptOut(r.Context(), optOut)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, optOut)
+	}))
+
+	router.HandleFunc("DELETE /notifications/opt-outs/{resource_id}", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		err := control.DeleteNotificationOptOut(r.Context(), token.Sub, r.PathValue("resource_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
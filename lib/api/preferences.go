@@ -0,0 +1,102 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"net/http"
+	"strings"
+)
+
+// NotificationPreferenceEndpoints let users manage their own notification preferences
+func NotificationPreferenceEndpoints(router *http.ServeMux, config config.Config, control Controller) {
+	if config.NotificationPreferenceCollection == "" || config.NotificationPreferenceCollection == "-" {
+		return
+	}
+
+	router.HandleFunc("GET /notifications/preferences", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		result, _, err := control.GetNotificationPreferences(r.Context(), token.Sub)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, result)
+	}))
+
+	router.HandleFunc("PUT /notifications/preferences", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		preferences := model.NotificationPreferences{}
+		err := json.NewDecoder(r.Body).Decode(&preferences)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if preferences.UserId != "" && preferences.UserId != token.Sub {
+			http.Error(w, "user_id in body does not match token", http.StatusBadRequest)
+			return
+		}
+		preferences.UserId = token.Sub
+		err = preferences.Validate()
+		if err == nil {
+			err = validatePreferenceChannel(config, preferences.Channel)
+		}
+		if err == nil && preferences.QuietHours != nil && (config.NotificationOutboxCollection == "" || config.NotificationOutboxCollection == "-") {
+			err = errors.New("quiet hours need the notification outbox, which is disabled")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = control.SetNotificationPreferences(r.Context(), preferences)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, preferences)
+	}))
+
+	router.HandleFunc("DELETE /notifications/preferences", authenticated(func(w http.ResponseWriter, r *http.Request, token Token) {
+		err := control.DeleteNotificationPreferences(r.Context(), token.Sub)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// validatePreferenceChannel allows comma separated names of configured channels.
+// addresses ("name:address") are rejected, because users could send notifications to arbitrary webhooks or mail addresses.
+func validatePreferenceChannel(config config.Config, spec string) error {
+	for _, element := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(element)
+		if name == "" {
+			continue
+		}
+		if strings.Contains(name, ":") {
+			return fmt.Errorf("invalid channel %q; addresses are not allowed", name)
+		}
+		if _, ok := config.NotificationChannels[name]; !ok && name != notifier.DefaultChannel {
+			return fmt.Errorf("unknown channel %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens provides oauth2 client-credentials tokens for outbound requests to platform services.
+// tokens are cached until shortly before they expire.
+type Tokens struct {
+	source oauth2.TokenSource
+}
+
+type credentialsKey struct {
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	scopes       string
+}
+
+var shared = map[credentialsKey]*Tokens{}
+var sharedMux sync.Mutex
+
+// Enabled returns false if config.AuthTokenUrl is "" or "-"
+func Enabled(config config.Config) bool {
+	return config.AuthTokenUrl != "" && config.AuthTokenUrl != "-"
+}
+
+// New returns the tokens for the client credentials of config; returns nil if auth is not enabled.
+// calls with the same credentials share their tokens.
+func New(config config.Config) *Tokens {
+	if !Enabled(config) {
+		return nil
+	}
+	key := credentialsKey{
+		tokenUrl:     config.AuthTokenUrl,
+		clientId:     config.AuthClientId,
+		clientSecret: config.AuthClientSecret,
+		scopes:       strings.Join(config.AuthScopes, " "),
+	}
+	sharedMux.Lock()
+	defer sharedMux.Unlock()
+	if result, ok := shared[key]; ok {
+		return result
+	}
+	credentials := clientcredentials.Config{
+		ClientID:     config.AuthClientId,
+		ClientSecret: config.AuthClientSecret,
+		TokenURL:     config.AuthTokenUrl,
+		Scopes:       config.AuthScopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: 10 * time.Second})
+	result := &Tokens{source: credentials.TokenSource(ctx)}
+	shared[key] = result
+	return result
+}
+
+// Authorization returns the Authorization header value ("Bearer <access-token>").
+// a nil *Tokens returns defaultValue.
+func (this *Tokens) Authorization(defaultValue string) (string, error) {
+	if this == nil {
+		return defaultValue, nil
+	}
+	token, err := this.source.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.Type() + " " + token.AccessToken, nil
+}
+
+// Client returns a http client adding the Authorization header to all requests.
+// a nil *Tokens returns http.DefaultClient.
+func (this *Tokens) Client() *http.Client {
+	if this == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: &oauth2.Transport{Source: this.source, Base: http.DefaultTransport}}
+}
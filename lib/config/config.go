@@ -33,8 +33,63 @@ type Config struct {
 	DeviceStateCollection                   string
 	HubStateCollection                      string
 	DeviceOfflineNotificationInfoCollection string
+	HubOfflineNotificationInfoCollection    string
+	HubMetadataCollection                   string
+	ProcessedEventCollection                string
+	ProcessedEventTtl                       string
 
-	NotificationUrl string
+	NotificationUrl            string
+	NotifyOnReconnect          bool
+	NotificationChannels       map[string]NotificationChannelConfig
+	DefaultNotificationChannel string
+	OwnerNotificationChannels  map[string]string
+
+	PermissionsV2Url             string
+	NotificationPermission       string
+	NotificationOptOutCollection string
+
+	NotificationPreferenceCollection string
+
+	NotificationAuditCollection string
+	NotificationAuditTtl        string
+
+	NotificationOutboxCollection    string
+	NotificationOutboxMaxAge        string
+	NotificationOutboxRetryInterval string
+	NotificationOutboxMaxBackoff    string
+
+	MaintenanceWindowCollection string
+
+	DutyCycleCollection    string
+	DutyCycleCheckInterval string
+	DutyCycleProfiles      map[string]DutyCycleProfile
+
+	FlappingCollection string
+
+	NeverConnectedCollection    string
+	NeverConnectedCheckInterval string
+
+	NotificationRules          []NotificationRule
+	NotificationRuleCollection string
+	NotificationRuleTimezone   string
+
+	LeaderElectionCollection string
+	LeaderElectionLease      string
+
+	ApiPort string
+
+	NotificationTemplateDir string
+	DefaultLocale           string
+	OwnerLocales            map[string]string
+
+	DigestCollection string
+	DigestWindow     string
+	DigestMaxDevices int64
+
+	OutageCollection string
+	OutageWindow     string
+	OutageMinDevices int64
+	OutagePercent    float64
 
 	InfluxdbUrl     string
 	InfluxdbDb      string
@@ -47,6 +102,14 @@ type Config struct {
 	DeviceTopic    string
 	HubTopic       string
 
+	TopicEncodings    map[string]string
+	SchemaRegistryUrl string
+
+	EnabledListeners  []string
+	DisabledListeners []string
+	ListenerTopics    map[string]string
+	Middlewares       []MiddlewareConfig
+
 	KafkaUrl     string
 	KafkaGroupId string
 	Debug        bool
@@ -55,11 +118,47 @@ type Config struct {
 
 	DeviceRepositoryUrl string
 
+	AuthTokenUrl     string
+	AuthClientId     string
+	AuthClientSecret string `config:"secret"`
+	AuthScopes       []string
+
 	ApiDocsProviderBaseUrl string
 
+	OtlpTraceUrl string
+
 	InitTopics bool
 }
 
+// MiddlewareConfig selects a listener.Middleware by Type; Options are interpreted by the middleware
+type MiddlewareConfig struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+// DutyCycleProfile describes devices which intentionally sleep for Interval and reconnect within Tolerance
+type DutyCycleProfile struct {
+	Interval  string `json:"interval"`
+	Tolerance string `json:"tolerance,omitempty"`
+}
+
+// NotificationRule sends Template (default "notification_rule") with Topic (default "notification_rule") for device or hub transitions matching Condition.
+// Condition is an expr expression (https://expr-lang.org); Type limits the rule to "device" or "hub" transitions.
+// Cooldown is the minimal time between notifications of the rule for the same device or hub.
+type NotificationRule struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	Condition string `json:"condition"`
+	Template  string `json:"template,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+	Cooldown  string `json:"cooldown,omitempty"`
+}
+
+type NotificationChannelConfig struct {
+	Type    string          `json:"type"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
 // loads config from json in location and used environment variables (e.g KafkaUrl --> ZOOKEEPER_URL)
 func Load(location string) (config Config, err error) {
 	file, error := os.Open(location)
@@ -120,14 +219,30 @@ func handleEnvironmentVars(config *Config) {
 				f, _ := strconv.ParseFloat(envValue, 64)
 				configValue.FieldByName(fieldName).SetFloat(f)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Slice && configValue.FieldByName(fieldName).Type().Elem().Kind() != reflect.String {
+				val := reflect.New(configValue.FieldByName(fieldName).Type())
+				err := json.Unmarshal([]byte(envValue), val.Interface())
+				if err != nil {
+					log.Println("WARNING: invalid json in environment variable", envName, err)
+				} else {
+					configValue.FieldByName(fieldName).Set(val.Elem())
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
 				val := []string{}
 				for _, element := range strings.Split(envValue, ",") {
 					val = append(val, strings.TrimSpace(element))
 				}
 				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Map && configValue.FieldByName(fieldName).Type().Elem().Kind() != reflect.String {
+				val := reflect.New(configValue.FieldByName(fieldName).Type())
+				err := json.Unmarshal([]byte(envValue), val.Interface())
+				if err != nil {
+					log.Println("WARNING: invalid json in environment variable", envName, err)
+				} else {
+					configValue.FieldByName(fieldName).Set(val.Elem())
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Map {
 				value := map[string]string{}
 				for _, element := range strings.Split(envValue, ",") {
 					keyVal := strings.Split(element, ":")
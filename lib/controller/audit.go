@@ -0,0 +1,122 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"github.com/google/uuid"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+func (this *Controller) auditEnabled() bool {
+	return this.config.NotificationAuditCollection != "" && this.config.NotificationAuditCollection != "-"
+}
+
+func (this *Controller) getNotificationAuditCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NotificationAuditCollection)
+	err := collection.EnsureIndex(mgo.Index{
+		Key:         []string{"time"},
+		ExpireAfter: parseDurationWithDefault(this.config.NotificationAuditTtl, 30*24*time.Hour),
+	})
+	if err != nil {
+		log.Fatal("error on getNotificationAuditCollection time index: ", err)
+	}
+	err = collection.EnsureIndexKey("device_id", "-time")
+	if err != nil {
+		log.Fatal("error on getNotificationAuditCollection device_id index: ", err)
+	}
+	err = collection.EnsureIndexKey("owner", "-time")
+	if err != nil {
+		log.Fatal("error on getNotificationAuditCollection owner index: ", err)
+	}
+	return
+}
+
+// auditNotification records a notification decision for the device log; errors are logged
+func (this *Controller) auditNotification(ctx context.Context, devicelog model.DeviceLog, decision string, reason string, level string, offlineSince int64) {
+	if !this.auditEnabled() {
+		return
+	}
+	entry := model.NotificationAuditEntry{
+		Id:        uuid.NewString(),
+		DeviceId:  devicelog.Id,
+		Owner:     devicelog.DeviceOwner,
+		Connected: devicelog.Connected,
+		Decision:  decision,
+		Reason:    reason,
+		Level:     level,
+		EventTime: devicelog.Time,
+		Time:      time.Now(),
+	}
+	if offlineSince != 0 {
+		since := time.Unix(offlineSince, 0)
+		entry.OfflineSince = &since
+	}
+	err := this.addNotificationAuditEntry(ctx, entry)
+	if err != nil {
+		log.Println("ERROR: addNotificationAuditEntry()", err)
+	}
+}
+
+func (this *Controller) addNotificationAuditEntry(ctx context.Context, entry model.NotificationAuditEntry) (err error) {
+	_, span := this.startMongoSpan(ctx, "addNotificationAuditEntry", this.config.NotificationAuditCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNotificationAuditCollection()
+	defer session.Close()
+	return collection.Insert(entry)
+}
+
+// ListNotificationAudit returns the matching audit entries, newest first
+func (this *Controller) ListNotificationAudit(ctx context.Context, query model.NotificationAuditQuery) (result []model.NotificationAuditEntry, err error) {
+	_, span := this.startMongoSpan(ctx, "ListNotificationAudit", this.config.NotificationAuditCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNotificationAuditCollection()
+	defer session.Close()
+	filter := bson.M{}
+	if query.DeviceId != "" {
+		filter["device_id"] = query.DeviceId
+	}
+	if query.Owner != "" {
+		filter["owner"] = query.Owner
+	}
+	if query.Decision != "" {
+		filter["decision"] = query.Decision
+	}
+	timeFilter := bson.M{}
+	if !query.From.IsZero() {
+		timeFilter["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timeFilter["$lt"] = query.To
+	}
+	if len(timeFilter) > 0 {
+		filter["time"] = timeFilter
+	}
+	find := collection.Find(filter).Sort("-time").Skip(query.Offset)
+	if query.Limit > 0 {
+		find = find.Limit(query.Limit)
+	}
+	result = []model.NotificationAuditEntry{}
+	err = find.All(&result)
+	return result, err
+}
@@ -17,12 +17,16 @@
 package controller
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
 	"log"
 
 	"time"
 
 	"github.com/influxdata/influxdb/client/v2"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (this *Controller) getInfluxDb() client.Client {
@@ -41,7 +45,17 @@ func (this *Controller) getInfluxDb() client.Client {
 	return this.influxdbInstance
 }
 
-func (this *Controller) logDeviceHistory(deviceLog model.DeviceLog) (err error) {
+func (this *Controller) startInfluxSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "influxdb "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemKey.String("influxdb"),
+		semconv.DBNamespace(this.config.InfluxdbDb),
+		semconv.DBOperationName(operation),
+	))
+}
+
+func (this *Controller) logDeviceHistory(ctx context.Context, deviceLog model.DeviceLog, sleeping bool) (err error) {
+	_, span := this.startInfluxSpan(ctx, "logDeviceHistory")
+	defer func() { tracing.End(span, err) }()
 	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
 		Database:  this.config.InfluxdbDb,
 		Precision: "s",
@@ -54,6 +68,7 @@ func (this *Controller) logDeviceHistory(deviceLog model.DeviceLog) (err error)
 	}
 	fields := map[string]interface{}{
 		"connected": deviceLog.Connected,
+		"sleeping":  sleeping,
 	}
 	pt, err := client.NewPoint(
 		"device",
@@ -68,7 +83,9 @@ func (this *Controller) logDeviceHistory(deviceLog model.DeviceLog) (err error)
 	return this.getInfluxDb().Write(bp)
 }
 
-func (this *Controller) logGatewayHistory(gatewayLog model.HubLog) error {
+func (this *Controller) logGatewayHistory(ctx context.Context, gatewayLog model.HubLog) (err error) {
+	_, span := this.startInfluxSpan(ctx, "logGatewayHistory")
+	defer func() { tracing.End(span, err) }()
 	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
 		Database:  this.config.InfluxdbDb,
 		Precision: "s",
@@ -95,7 +112,9 @@ func (this *Controller) logGatewayHistory(gatewayLog model.HubLog) error {
 	return this.getInfluxDb().Write(bp)
 }
 
-func (this *Controller) deleteDeviceLog(deviceId string) (err error) {
+func (this *Controller) deleteDeviceLog(ctx context.Context, deviceId string) (err error) {
+	_, span := this.startInfluxSpan(ctx, "deleteDeviceLog")
+	defer func() { tracing.End(span, err) }()
 	resp, err := this.getInfluxDb().Query(client.NewQuery(`DELETE FROM device WHERE "device"='`+deviceId+`'`, this.config.InfluxdbDb, "s"))
 	if err != nil {
 		return err
@@ -103,7 +122,9 @@ func (this *Controller) deleteDeviceLog(deviceId string) (err error) {
 	return resp.Error()
 }
 
-func (this *Controller) deleteGatewayLog(gwId string) (err error) {
+func (this *Controller) deleteGatewayLog(ctx context.Context, gwId string) (err error) {
+	_, span := this.startInfluxSpan(ctx, "deleteGatewayLog")
+	defer func() { tracing.End(span, err) }()
 	resp, err := this.getInfluxDb().Query(client.NewQuery(`DELETE FROM gateway WHERE "gateway"='`+gwId+`'`, this.config.InfluxdbDb, "s"))
 	if err != nil {
 		return err
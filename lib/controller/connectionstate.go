@@ -17,12 +17,18 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 	"time"
 )
 
-func (this *Controller) setHubState(gatewayLog model.HubLog) (update bool, err error) {
+func (this *Controller) setHubState(ctx context.Context, gatewayLog model.HubLog) (update bool, err error) {
+	_, span := this.startMongoSpan(ctx, "setHubState", this.config.HubStateCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getHubStateCollection()
 	defer session.Close()
 	count, err := collection.Find(bson.M{"gateway": gatewayLog.Id, "online": gatewayLog.Connected}).Limit(1).Count()
@@ -36,28 +42,58 @@ func (this *Controller) setHubState(gatewayLog model.HubLog) (update bool, err e
 	return
 }
 
-func (this *Controller) setDeviceState(deviceLog model.DeviceLog) (update bool, err error) {
+// setDeviceState updates the state if online or sleeping changed; sleeping marks devices which are offline as expected by their duty cycle
+func (this *Controller) setDeviceState(ctx context.Context, deviceLog model.DeviceLog, sleeping bool) (update bool, err error) {
+	_, span := this.startMongoSpan(ctx, "setDeviceState", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getDeviceStateCollection()
 	defer session.Close()
-	count, err := collection.Find(bson.M{"device": deviceLog.Id, "online": deviceLog.Connected}).Limit(1).Count()
+	query := bson.M{"device": deviceLog.Id, "online": deviceLog.Connected, "sleeping": sleeping}
+	if !sleeping {
+		query["sleeping"] = bson.M{"$ne": true} //states stored before duty cycles have no sleeping field
+	}
+	count, err := collection.Find(query).Limit(1).Count()
 	if err != nil {
 		return false, err
 	}
 	update = count == 0
 	if update {
-		_, err = collection.Upsert(bson.M{"device": deviceLog.Id}, DeviceState{Device: deviceLog.Id, Online: deviceLog.Connected, Since: time.Now().Unix()})
+		_, err = collection.Upsert(bson.M{"device": deviceLog.Id}, DeviceState{Device: deviceLog.Id, Online: deviceLog.Connected, Sleeping: sleeping, Owner: deviceLog.DeviceOwner, Since: time.Now().Unix()})
+	} else if deviceLog.DeviceOwner != "" {
+		//backfill states stored before the owner was recorded
+		err = collection.Update(bson.M{"device": deviceLog.Id, "owner": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"owner": deviceLog.DeviceOwner}})
+		if errors.Is(err, mgo.ErrNotFound) {
+			err = nil
+		}
 	}
 	return
 }
 
-func (this *Controller) deleteHubState(gwId string) (err error) {
+// setDeviceStateOwner updates the owner of an existing device state, e.g. on device commands
+func (this *Controller) setDeviceStateOwner(ctx context.Context, deviceId string, owner string) (err error) {
+	_, span := this.startMongoSpan(ctx, "setDeviceStateOwner", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDeviceStateCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device": deviceId}, bson.M{"$set": bson.M{"owner": owner}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (this *Controller) deleteHubState(ctx context.Context, gwId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "deleteHubState", this.config.HubStateCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getHubStateCollection()
 	defer session.Close()
 	_, err = collection.RemoveAll(bson.M{"gateway": gwId})
 	return
 }
 
-func (this *Controller) deleteDeviceState(deviceId string) (err error) {
+func (this *Controller) deleteDeviceState(ctx context.Context, deviceId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "deleteDeviceState", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getDeviceStateCollection()
 	defer session.Close()
 	_, err = collection.RemoveAll(bson.M{"device": deviceId})
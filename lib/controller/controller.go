@@ -17,24 +17,54 @@
 package controller
 
 import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
 	devicerepo "github.com/SENERGY-Platform/device-repository/lib/client"
+	permv2 "github.com/SENERGY-Platform/permissions-v2/pkg/client"
+	"github.com/google/uuid"
 	"github.com/influxdata/influxdb/client/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/mgo.v2"
 	"log"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var tracer = otel.Tracer("github.com/SENERGY-Platform/connection-log-worker/lib/controller")
+
 type Controller struct {
-	config           config.Config
-	mongoDbInstance  *mgo.Session
-	mongoDbOnce      sync.Once
-	influxdbInstance client.Client
-	influxdbOnce     sync.Once
-	roundTime        time.Duration
-	deviceRepo       devicerepo.Interface
+	config            config.Config
+	mongoDbInstance   *mgo.Session
+	mongoDbOnce       sync.Once
+	influxdbInstance  client.Client
+	influxdbOnce      sync.Once
+	roundTime         time.Duration
+	processedEventTtl time.Duration
+	deviceRepo        devicerepo.Interface
+	templates         *NotificationTemplates
+	digestWindow      time.Duration
+	outageWindow      time.Duration
+	rules             []notificationRule
+	ruleLocation      *time.Location
+	notifier          *notifier.Channels
+	permissions       permv2.Client
+	tokens            *auth.Tokens
+	instanceId        string
+	leaderLease       time.Duration
+	leaderJobs        atomic.Pointer[context.Context]
+
+	outboxTrigger       chan struct{}
+	outboxMaxAge        time.Duration
+	outboxRetryInterval time.Duration
+	outboxMaxBackoff    time.Duration
 }
 
 func New(config config.Config) *Controller {
@@ -42,54 +72,204 @@ func New(config config.Config) *Controller {
 	if err != nil {
 		roundTime = time.Minute
 	}
-	return &Controller{config: config, roundTime: roundTime, deviceRepo: devicerepo.NewClient(config.DeviceRepositoryUrl, nil)}
+	processedEventTtl, err := time.ParseDuration(config.ProcessedEventTtl)
+	if err != nil {
+		processedEventTtl = 24 * time.Hour
+	}
+	channels, err := notifier.New(config)
+	if err != nil {
+		log.Fatal("unable to create notification channels: ", err)
+	}
+	digestWindow := time.Duration(0)
+	if config.DigestWindow != "" && config.DigestWindow != "-" {
+		digestWindow, err = ParseMonitorDuration(config.DigestWindow)
+		if err != nil {
+			log.Println("ERROR: invalid DigestWindow; digest disabled", err)
+			digestWindow = 0
+		}
+	}
+	outageWindow := time.Duration(0)
+	if config.OutageWindow != "" && config.OutageWindow != "-" && config.OutageCollection != "" && config.OutageCollection != "-" {
+		outageWindow, err = ParseMonitorDuration(config.OutageWindow)
+		if err != nil {
+			log.Println("ERROR: invalid OutageWindow; outage detection disabled", err)
+			outageWindow = 0
+		}
+	}
+	templates, err := LoadNotificationTemplates(config.NotificationTemplateDir, config.DefaultLocale)
+	if err != nil {
+		log.Println("ERROR: unable to load notification templates; use defaults", err)
+		templates, err = LoadNotificationTemplates("", config.DefaultLocale)
+		if err != nil {
+			log.Fatal("unable to load default notification templates: ", err)
+		}
+	}
+	rules, err := compileNotificationRules(config.NotificationRules)
+	if err != nil {
+		log.Fatal("invalid NotificationRules: ", err)
+	}
+	err = ValidateNotificationRuleTemplates(config.NotificationRules, templates)
+	if err != nil {
+		log.Fatal("invalid NotificationRules: ", err)
+	}
+	ruleLocation, err := time.LoadLocation(withDefault(config.NotificationRuleTimezone, "UTC"))
+	if err != nil {
+		log.Fatal("invalid NotificationRuleTimezone: ", err)
+	}
+	var permissions permv2.Client
+	if config.PermissionsV2Url != "" && config.PermissionsV2Url != "-" {
+		err = ValidateNotificationPermission(config.NotificationPermission)
+		if err != nil {
+			log.Fatal("invalid NotificationPermission: ", err)
+		}
+		permissions = permv2.New(config.PermissionsV2Url)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &Controller{
+		config:              config,
+		roundTime:           roundTime,
+		processedEventTtl:   processedEventTtl,
+		deviceRepo:          devicerepo.NewClient(config.DeviceRepositoryUrl, nil),
+		templates:           templates,
+		digestWindow:        digestWindow,
+		outageWindow:        outageWindow,
+		rules:               rules,
+		ruleLocation:        ruleLocation,
+		notifier:            channels,
+		permissions:         permissions,
+		tokens:              auth.New(config),
+		instanceId:          hostname + "-" + uuid.NewString(),
+		leaderLease:         parseDurationWithDefault(config.LeaderElectionLease, 15*time.Second),
+		outboxTrigger:       make(chan struct{}, 1),
+		outboxMaxAge:        parseDurationWithDefault(config.NotificationOutboxMaxAge, 24*time.Hour),
+		outboxRetryInterval: parseDurationWithDefault(config.NotificationOutboxRetryInterval, 10*time.Second),
+		outboxMaxBackoff:    parseDurationWithDefault(config.NotificationOutboxMaxBackoff, 10*time.Minute),
+	}
+}
+
+// Start starts the background jobs of the controller.
+// with leader election, the periodic jobs run only on the instance holding the lease.
+// the outbox dispatcher runs on every instance, because outbox entries are leased individually.
+func (this *Controller) Start(ctx context.Context) {
+	if this.outboxEnabled() {
+		this.startOutboxDispatcher(ctx)
+	}
+	this.checkQuietHoursPreferences(ctx)
+	if this.leaderElectionEnabled() {
+		this.startLeaderElection(ctx, this.startPeriodicJobs)
+	} else {
+		this.startPeriodicJobs(ctx)
+	}
+}
+
+func (this *Controller) startPeriodicJobs(ctx context.Context) {
+	if this.digestEnabled() {
+		this.startDigestLoop(ctx)
+	}
+	if this.dutyCycleEnabled() {
+		this.startDutyCycleCheck(ctx)
+	}
+	if this.neverConnectedEnabled() {
+		this.startNeverConnectedCheck(ctx)
+	}
 }
 
-func (this *Controller) LogHub(hublog model.HubLog) error {
+func parseDurationWithDefault(value string, defaultValue time.Duration) time.Duration {
+	result, err := time.ParseDuration(value)
+	if err != nil || result <= 0 {
+		return defaultValue
+	}
+	return result
+}
+
+func (this *Controller) LogHub(ctx context.Context, hublog model.HubLog) error {
 	if this.config.Debug {
 		log.Println("DEBUG: handle hub log update", hublog)
 	}
 	if this.config.DeviceRepositoryUrl != "" && this.config.DeviceRepositoryUrl != "-" {
-		err, _ := this.deviceRepo.SetHubConnectionState(devicerepo.InternalAdminToken, hublog.Id, hublog.Connected)
+		err := this.setHubConnectionState(ctx, hublog)
 		if err != nil {
 			return err
 		}
 	}
-	updated, err := this.setHubState(hublog)
+	updated, err := this.setHubState(ctx, hublog)
 	if err != nil {
 		return err
 	}
 	if updated {
-		err = this.logGatewayHistory(hublog)
+		err = this.logGatewayHistory(ctx, hublog)
+		if err != nil {
+			return err
+		}
+		this.handleHubOutage(ctx, hublog)
+		this.handleHubRules(ctx, hublog)
 	}
-	return err
+	if time.Since(hublog.Time) < time.Hour {
+		this.handleHubNotifications(ctx, hublog)
+	}
+	return nil
 }
 
-func (this *Controller) LogDevice(devicelog model.DeviceLog) error {
+func (this *Controller) LogDevice(ctx context.Context, devicelog model.DeviceLog) error {
 	if this.config.Debug {
 		log.Printf("DEBUG: handle device log update %#v\n", devicelog)
 	}
 	if this.config.DeviceRepositoryUrl != "" && this.config.DeviceRepositoryUrl != "-" {
-		err, _ := this.deviceRepo.SetDeviceConnectionState(devicerepo.InternalAdminToken, devicelog.Id, devicelog.Connected)
+		err := this.setDeviceConnectionState(ctx, devicelog)
 		if err != nil {
 			return err
 		}
 	}
-	updated, err := this.setDeviceState(devicelog)
+	sleeping := this.handleDutyCycle(ctx, devicelog)
+	updated, err := this.setDeviceState(ctx, devicelog, sleeping)
 	if err != nil {
 		return err
 	}
 	if updated {
-		err = this.logDeviceHistory(devicelog)
+		if this.neverConnectedEnabled() {
+			err = this.removeNeverConnectedDevice(ctx, devicelog.Id)
+			if err != nil {
+				return err
+			}
+		}
+		err = this.logDeviceHistory(ctx, devicelog, sleeping)
 		if err != nil {
 			return err
 		}
+		this.handleDeviceOutage(ctx, devicelog, sleeping)
+		this.handleFlapping(ctx, devicelog, sleeping)
+		this.handleDeviceRules(ctx, devicelog)
 	}
 	if time.Since(devicelog.Time) < time.Hour {
-		this.handleNotifications(devicelog)
+		this.handleNotifications(ctx, devicelog)
 	} else if this.config.Debug {
 		log.Printf("DEBUG: devicelog older than an our -> ignore for handleNotifications")
 	}
 
 	return err
 }
+
+func (this *Controller) setHubConnectionState(ctx context.Context, hublog model.HubLog) (err error) {
+	_, span := tracer.Start(ctx, "device-repository SetHubConnectionState", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("hub.id", hublog.Id)))
+	defer func() { tracing.End(span, err) }()
+	token, err := this.tokens.Authorization(devicerepo.InternalAdminToken)
+	if err != nil {
+		return err
+	}
+	err, _ = this.deviceRepo.SetHubConnectionState(token, hublog.Id, hublog.Connected)
+	return err
+}
+
+func (this *Controller) setDeviceConnectionState(ctx context.Context, devicelog model.DeviceLog) (err error) {
+	_, span := tracer.Start(ctx, "device-repository SetDeviceConnectionState", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("device.id", devicelog.Id)))
+	defer func() { tracing.End(span, err) }()
+	token, err := this.tokens.Authorization(devicerepo.InternalAdminToken)
+	if err != nil {
+		return err
+	}
+	err, _ = this.deviceRepo.SetDeviceConnectionState(token, devicelog.Id, devicelog.Connected)
+	return err
+}
@@ -17,16 +17,53 @@
 package controller
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
-func (this *Controller) UpdateDevice(command model.DeviceCommand) error {
+func (this *Controller) UpdateDevice(ctx context.Context, command model.DeviceCommand) error {
 	if command.Command == "DELETE" {
-		err := this.deleteDeviceLog(command.Id)
+		err := this.deleteDeviceLog(ctx, command.Id)
 		if err != nil {
 			return err
 		}
-		return this.deleteDeviceState(command.Id)
+		err = this.deleteDeviceState(ctx, command.Id)
+		if err != nil {
+			return err
+		}
+		if this.dutyCycleEnabled() {
+			err = this.removeSleepingDevice(ctx, command.Id)
+			if err != nil {
+				return err
+			}
+		}
+		if this.flappingEnabled() {
+			err = this.removeFlappingDevice(ctx, command.Id)
+			if err != nil {
+				return err
+			}
+		}
+		if this.neverConnectedEnabled() {
+			err = this.removeNeverConnectedDevice(ctx, command.Id)
+			if err != nil {
+				return err
+			}
+		}
+		if this.rulesEnabled() {
+			return this.removeRuleState(ctx, RuleTypeDevice, command.Id)
+		}
+		return nil
+	}
+	if command.Command == "PUT" {
+		if owner := withDefault(command.Device.OwnerId, command.Owner); owner != "" {
+			err := this.setDeviceStateOwner(ctx, command.Id, owner)
+			if err != nil {
+				return err
+			}
+		}
+		if this.neverConnectedEnabled() {
+			return this.handleNeverConnected(ctx, command)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+// NotificationDigest collects the offline notifications of a recipient; Owner is the recipient, which may be a user the devices are shared with
+type NotificationDigest struct {
+	Owner       string        `json:"owner" bson:"owner"`
+	WindowStart int64         `json:"window_start" bson:"window_start"`
+	Entries     []DigestEntry `json:"entries" bson:"entries"`
+}
+
+// DigestEntry is a pending offline notification; Owner is the owner of the device
+type DigestEntry struct {
+	DeviceId     string `json:"device_id" bson:"device_id"`
+	Owner        string `json:"device_owner,omitempty" bson:"device_owner,omitempty"`
+	DeviceName   string `json:"device_name" bson:"device_name"`
+	HubId        string `json:"hub_id" bson:"hub_id"`
+	HubName      string `json:"hub_name" bson:"hub_name"`
+	OfflineSince int64  `json:"offline_since" bson:"offline_since"`
+	Duration     string `json:"duration" bson:"duration"`
+	Severity     string `json:"severity" bson:"severity"`
+	Topic        string `json:"topic" bson:"topic"`
+	Locale       string `json:"locale" bson:"locale"`
+	Channel      string `json:"channel" bson:"channel"`
+	MessageKey   string `json:"message_key,omitempty" bson:"message_key,omitempty"`
+}
+
+func (this *Controller) digestEnabled() bool {
+	return this.digestWindow > 0
+}
+
+func (this *Controller) getDigestCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.DigestCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"owner"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getDigestCollection owner index: ", err)
+	}
+	err = collection.EnsureIndexKey("window_start")
+	if err != nil {
+		log.Fatal("error on getDigestCollection window_start index: ", err)
+	}
+	return
+}
+
+func (this *Controller) addToDigest(ctx context.Context, owner string, entry DigestEntry) (err error) {
+	_, span := this.startMongoSpan(ctx, "addToDigest", this.config.DigestCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDigestCollection()
+	defer session.Close()
+	selector := bson.M{"owner": owner}
+	entry.MessageKey = model.MessageKeyFromContext(ctx)
+	if entry.MessageKey != "" {
+		//a repeated processing of the consumed message matches no document and fails on the unique owner index
+		selector["entries.message_key"] = bson.M{"$ne": entry.MessageKey}
+	}
+	_, err = collection.Upsert(selector, bson.M{
+		"$setOnInsert": bson.M{"window_start": time.Now().Unix()},
+		"$push":        bson.M{"entries": entry},
+	})
+	if mgo.IsDup(err) && entry.MessageKey != "" {
+		//the insert may also collide with a concurrent insert for the owner; only a stored key means the entry exists
+		count, countErr := collection.Find(bson.M{"owner": owner, "entries.message_key": entry.MessageKey}).Limit(1).Count()
+		if countErr == nil && count > 0 {
+			return nil
+		}
+	}
+	return err
+}
+
+// takeDigest removes and returns the digest of owner
+func (this *Controller) takeDigest(ctx context.Context, owner string) (digest NotificationDigest, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "takeDigest", this.config.DigestCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDigestCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{"owner": owner}).Apply(mgo.Change{Remove: true}, &digest)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return digest, false, nil
+	}
+	if err != nil {
+		return digest, false, err
+	}
+	return digest, true, nil
+}
+
+// restoreDigest merges a digest, which could not be sent, back into the collection
+func (this *Controller) restoreDigest(ctx context.Context, digest NotificationDigest) (err error) {
+	_, span := this.startMongoSpan(ctx, "restoreDigest", this.config.DigestCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDigestCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"owner": digest.Owner}, bson.M{
+		"$min":  bson.M{"window_start": digest.WindowStart},
+		"$push": bson.M{"entries": bson.M{"$each": digest.Entries, "$position": 0}},
+	})
+	return err
+}
+
+// removeFromDigests removes the pending entries of a reconnected device from all digests and returns the recipients of the removed entries
+func (this *Controller) removeFromDigests(ctx context.Context, deviceId string) (recipients []string, err error) {
+	_, span := this.startMongoSpan(ctx, "removeFromDigests", this.config.DigestCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDigestCollection()
+	defer session.Close()
+	list := []NotificationDigest{}
+	err = collection.Find(bson.M{"entries.device_id": deviceId}).Select(bson.M{"owner": 1}).All(&list)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	_, err = collection.UpdateAll(bson.M{"entries.device_id": deviceId}, bson.M{"$pull": bson.M{"entries": bson.M{"device_id": deviceId}}})
+	if err != nil {
+		return nil, err
+	}
+	for _, digest := range list {
+		recipients = append(recipients, digest.Owner)
+	}
+	return recipients, nil
+}
+
+func (this *Controller) getClosedDigestOwners(ctx context.Context, now time.Time) (owners []string, err error) {
+	_, span := this.startMongoSpan(ctx, "getClosedDigestOwners", this.config.DigestCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDigestCollection()
+	defer session.Close()
+	list := []NotificationDigest{}
+	err = collection.Find(bson.M{"window_start": bson.M{"$lte": now.Add(-this.digestWindow).Unix()}}).Select(bson.M{"owner": 1}).All(&list)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range list {
+		owners = append(owners, element.Owner)
+	}
+	return owners, nil
+}
+
+// FlushDigests sends the digests of all owners whose window is closed
+func (this *Controller) FlushDigests(ctx context.Context) error {
+	owners, err := this.getClosedDigestOwners(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		digest, found, err := this.takeDigest(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if !found || len(digest.Entries) == 0 {
+			continue
+		}
+		err = this.sendDigestNotification(ctx, digest)
+		if err != nil {
+			log.Println("ERROR: unable to send digest notification", owner, err)
+			err = this.restoreDigest(ctx, digest)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (this *Controller) startDigestLoop(ctx context.Context) {
+	interval := min(max(this.digestWindow/4, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := this.FlushDigests(ctx)
+				if err != nil {
+					log.Println("ERROR: FlushDigests()", err)
+				}
+			}
+		}
+	}()
+}
+
+func (this *Controller) sendDigestNotification(ctx context.Context, digest NotificationDigest) error {
+	if this.config.Debug {
+		log.Printf("DEBUG: send digest notification for %v with %v entries\n", digest.Owner, len(digest.Entries))
+	}
+	first := digest.Entries[0]
+	if len(digest.Entries) == 1 {
+		return this.sendTemplateNotification(ctx, DeviceOfflineTemplate, first.Locale, first.Channel, withDefault(first.Topic, "device_offline"), first.templateData(digest.Owner), 0)
+	}
+	data := NotificationTemplateData{
+		Recipient:    digest.Owner,
+		Time:         time.Now(),
+		OfflineSince: time.Unix(digest.WindowStart, 0),
+		Devices:      len(digest.Entries),
+	}
+	for i, entry := range digest.Entries {
+		if int64(i) >= this.config.DigestMaxDevices && this.config.DigestMaxDevices > 0 {
+			data.More = len(digest.Entries) - i
+			break
+		}
+		data.Entries = append(data.Entries, entry.templateData(digest.Owner))
+	}
+	return this.sendTemplateNotification(ctx, DeviceOfflineDigestTemplate, first.Locale, first.Channel, "device_offline", data, 0)
+}
+
+// templateData uses the recipient of the digest as owner for entries stored without device owner
+func (this DigestEntry) templateData(recipient string) NotificationTemplateData {
+	return NotificationTemplateData{
+		DeviceId:     this.DeviceId,
+		DeviceName:   this.DeviceName,
+		HubId:        this.HubId,
+		HubName:      this.HubName,
+		Owner:        withDefault(this.Owner, recipient),
+		Recipient:    recipient,
+		Duration:     this.Duration,
+		OfflineSince: time.Unix(this.OfflineSince, 0),
+		Time:         time.Now(),
+		Severity:     this.Severity,
+	}
+}
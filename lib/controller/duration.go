@@ -0,0 +1,134 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MonitorFormatDescription = "allowed are durations like 30s, 10m, 1h30m, 1d or 2w, ISO-8601 durations like PT10M or P1DT2H, comma separated thresholds like 10m,1h,1d, json level lists like [{\"after\":\"10m\"}] or off"
+
+// MonitorOffKeywords disable the connection monitoring of a device or hub
+var MonitorOffKeywords = []string{"off", "false", "disabled", "none", "never", "-"}
+
+var ErrInvalidDuration = errors.New("invalid duration")
+
+var durationElement = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?)(ns|us|µs|ms|s|m|h|d|w)`)
+var isoDuration = regexp.MustCompile(`^P(?:([0-9]+(?:[.,][0-9]+)?)Y)?(?:([0-9]+(?:[.,][0-9]+)?)M)?(?:([0-9]+(?:[.,][0-9]+)?)W)?(?:([0-9]+(?:[.,][0-9]+)?)D)?(?:T(?:([0-9]+(?:[.,][0-9]+)?)H)?(?:([0-9]+(?:[.,][0-9]+)?)M)?(?:([0-9]+(?:[.,][0-9]+)?)S)?)?$`)
+
+const day = 24 * time.Hour
+
+// isoDurationUnits are the units of the isoDuration groups; years and months are approximated with 365 and 30 days
+var isoDurationUnits = []time.Duration{365 * day, 30 * day, 7 * day, day, time.Hour, time.Minute, time.Second}
+
+// IsMonitorOff checks if value is one of MonitorOffKeywords
+func IsMonitorOff(value string) bool {
+	return slices.Contains(MonitorOffKeywords, strings.ToLower(strings.TrimSpace(value)))
+}
+
+// splitMonitorDurations splits a comma separated list of durations.
+// a comma inside an ISO-8601 duration with decimal comma (e.g. "PT1,5H,1d") does not split the list:
+// an ISO-8601 duration can not end with a digit, so the following part continues it.
+func splitMonitorDurations(value string) (result []string) {
+	for _, part := range strings.Split(value, ",") {
+		if len(result) > 0 {
+			previous := strings.TrimSpace(result[len(result)-1])
+			if strings.HasPrefix(strings.ToUpper(previous), "P") && previous[len(previous)-1] >= '0' && previous[len(previous)-1] <= '9' &&
+				part != "" && part[0] >= '0' && part[0] <= '9' {
+				result[len(result)-1] = result[len(result)-1] + "," + part
+				continue
+			}
+		}
+		result = append(result, part)
+	}
+	return result
+}
+
+// ParseMonitorDuration parses go durations (1h30m), durations with days and weeks (1d, 2w, 1d12h)
+// and ISO-8601 durations (P1DT2H)
+func ParseMonitorDuration(value string) (result time.Duration, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("%w: empty value; %v", ErrInvalidDuration, MonitorFormatDescription)
+	}
+	if strings.HasPrefix(strings.ToUpper(value), "P") {
+		result, err = parseIsoDuration(strings.ToUpper(value))
+	} else {
+		result, err = parseExtendedDuration(value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w %q; %v", ErrInvalidDuration, value, MonitorFormatDescription)
+	}
+	return result, nil
+}
+
+func parseExtendedDuration(value string) (result time.Duration, err error) {
+	rest := value
+	for rest != "" {
+		match := durationElement.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, ErrInvalidDuration
+		}
+		rest = rest[len(match[0]):]
+		var element time.Duration
+		switch match[2] {
+		case "d":
+			element, err = multiply(match[1], day)
+		case "w":
+			element, err = multiply(match[1], 7*day)
+		default:
+			element, err = time.ParseDuration(match[0])
+		}
+		if err != nil {
+			return 0, err
+		}
+		result += element
+	}
+	return result, nil
+}
+
+func parseIsoDuration(value string) (result time.Duration, err error) {
+	match := isoDuration.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, ErrInvalidDuration
+	}
+	for i, unit := range isoDurationUnits {
+		if match[i+1] == "" {
+			continue
+		}
+		element, err := multiply(strings.ReplaceAll(match[i+1], ",", "."), unit)
+		if err != nil {
+			return 0, err
+		}
+		result += element
+	}
+	return result, nil
+}
+
+func multiply(number string, unit time.Duration) (time.Duration, error) {
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(f * float64(unit)), nil
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"strings"
+	"time"
+)
+
+const dutyCycleLease = time.Minute
+
+// DutyCycle describes a device which disconnects intentionally and is expected to reconnect within Interval + Tolerance
+type DutyCycle struct {
+	Interval  time.Duration
+	Tolerance time.Duration
+}
+
+// Deadline returns the latest expected reconnect of a device which disconnected at sleepingSince
+func (this DutyCycle) Deadline(sleepingSince time.Time) time.Time {
+	return sleepingSince.Add(this.Interval + this.Tolerance)
+}
+
+// ParseDutyCycle parses a duty_cycle value.
+// allowed are the name of a profile in profiles, an interval ("1h") or an interval with tolerance ("1h,10m").
+// durations are parsed by ParseMonitorDuration; the tolerance defaults to a tenth of the interval.
+// values in MonitorOffKeywords result in ok == false.
+func ParseDutyCycle(value string, profiles map[string]config.DutyCycleProfile) (result DutyCycle, ok bool, err error) {
+	value = strings.TrimSpace(value)
+	if IsMonitorOff(value) {
+		return result, false, nil
+	}
+	parts := splitMonitorDurations(value)
+	interval, tolerance := parts[0], strings.Join(parts[1:], ",")
+	if profile, isProfile := profiles[value]; isProfile {
+		interval, tolerance = profile.Interval, profile.Tolerance
+	}
+	result.Interval, err = ParseMonitorDuration(interval)
+	if err != nil {
+		return result, false, fmt.Errorf("invalid duty cycle interval: %w", err)
+	}
+	if result.Interval <= 0 {
+		return result, false, errors.New("invalid duty cycle interval: must be positive")
+	}
+	if strings.TrimSpace(tolerance) == "" {
+		result.Tolerance = result.Interval / 10
+	} else {
+		result.Tolerance, err = ParseMonitorDuration(tolerance)
+		if err != nil {
+			return result, false, fmt.Errorf("invalid duty cycle tolerance: %w", err)
+		}
+	}
+	return result, true, nil
+}
+
+// SleepingDevice is a disconnected device with duty cycle.
+// Missed is set if the device did not reconnect until WakeDeadline; the device is handled as offline from then on.
+type SleepingDevice struct {
+	DeviceId      string            `json:"device_id" bson:"device_id"`
+	DeviceName    string            `json:"device_name" bson:"device_name"`
+	Owner         string            `json:"owner" bson:"owner"`
+	Locale        string            `json:"locale" bson:"locale"`
+	Channel       string            `json:"channel" bson:"channel"`
+	Metadata      map[string]string `json:"metadata" bson:"metadata"`
+	SleepingSince int64             `json:"sleeping_since" bson:"sleeping_since"`
+	WakeDeadline  time.Time         `json:"wake_deadline" bson:"wake_deadline"`
+	LockedUntil   time.Time         `json:"locked_until" bson:"locked_until"`
+	Missed        bool              `json:"missed" bson:"missed"`
+}
+
+func (this *Controller) dutyCycleEnabled() bool {
+	return this.config.DutyCycleCollection != "" && this.config.DutyCycleCollection != "-"
+}
+
+// getDutyCycle returns the duty cycle of the device; invalid values are logged and ignored
+func (this *Controller) getDutyCycle(devicelog model.DeviceLog) (result DutyCycle, ok bool) {
+	if !this.dutyCycleEnabled() || devicelog.DutyCycle == "" {
+		return result, false
+	}
+	result, ok, err := ParseDutyCycle(devicelog.DutyCycle, this.config.DutyCycleProfiles)
+	if err != nil {
+		log.Println("WARNING: invalid duty_cycle value", devicelog.Id, err)
+		return result, false
+	}
+	return result, ok
+}
+
+// handleDutyCycle tracks disconnected devices with duty cycle and returns true if the device is sleeping
+func (this *Controller) handleDutyCycle(ctx context.Context, devicelog model.DeviceLog) (sleeping bool) {
+	if !this.dutyCycleEnabled() {
+		return false
+	}
+	dutyCycle, ok := this.getDutyCycle(devicelog)
+	if devicelog.Connected || !ok {
+		err := this.removeSleepingDevice(ctx, devicelog.Id)
+		if err != nil {
+			log.Println("ERROR: removeSleepingDevice()", err)
+		}
+		return false
+	}
+	device, err := this.setSleepingDevice(ctx, SleepingDevice{
+		DeviceId:      devicelog.Id,
+		DeviceName:    devicelog.DeviceName,
+		Owner:         devicelog.DeviceOwner,
+		Locale:        devicelog.Locale,
+		Channel:       devicelog.NotificationChannel,
+		Metadata:      devicelog.Metadata,
+		SleepingSince: devicelog.Time.Unix(),
+		WakeDeadline:  dutyCycle.Deadline(devicelog.Time),
+	})
+	if err != nil {
+		log.Println("ERROR: setSleepingDevice()", err)
+		return false
+	}
+	return !device.Missed
+}
+
+func (this *Controller) getDutyCycleCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.DutyCycleCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"device_id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getDutyCycleCollection device_id index: ", err)
+	}
+	err = collection.EnsureIndexKey("missed", "wake_deadline")
+	if err != nil {
+		log.Fatal("error on getDutyCycleCollection wake_deadline index: ", err)
+	}
+	return
+}
+
+// setSleepingDevice stores the device if it is not already sleeping and returns the stored state.
+// repeated disconnect messages keep the original wake deadline.
+func (this *Controller) setSleepingDevice(ctx context.Context, device SleepingDevice) (result SleepingDevice, err error) {
+	_, span := this.startMongoSpan(ctx, "setSleepingDevice", this.config.DutyCycleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDutyCycleCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{"device_id": device.DeviceId}).Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"device_name": device.DeviceName,
+				"owner":       device.Owner,
+				"locale":      device.Locale,
+				"channel":     device.Channel,
+				"metadata":    device.Metadata,
+			},
+			"$setOnInsert": bson.M{
+				"sleeping_since": device.SleepingSince,
+				"wake_deadline":  device.WakeDeadline,
+				"locked_until":   time.Time{},
+				"missed":         false,
+			},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &result)
+	return result, err
+}
+
+func (this *Controller) removeSleepingDevice(ctx context.Context, deviceId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeSleepingDevice", this.config.DutyCycleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDutyCycleCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"device_id": deviceId})
+	return err
+}
+
+// claimMissedWakeup locks a sleeping device which missed its wake deadline
+func (this *Controller) claimMissedWakeup(now time.Time) (device SleepingDevice, found bool, err error) {
+	session, collection := this.getDutyCycleCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{
+		"missed":        false,
+		"wake_deadline": bson.M{"$lte": now},
+		"locked_until":  bson.M{"$lte": now},
+	}).Sort("wake_deadline").Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"locked_until": now.Add(dutyCycleLease)}},
+		ReturnNew: true,
+	}, &device)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return device, false, nil
+	}
+	if err != nil {
+		return device, false, err
+	}
+	return device, true, nil
+}
+
+// markMissedWakeup returns false if the device reconnected or disconnected again since it was claimed
+func (this *Controller) markMissedWakeup(ctx context.Context, device SleepingDevice) (ok bool, err error) {
+	_, span := this.startMongoSpan(ctx, "markMissedWakeup", this.config.DutyCycleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDutyCycleCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device_id": device.DeviceId, "sleeping_since": device.SleepingSince, "missed": false}, bson.M{"$set": bson.M{"missed": true}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// resetMissedWakeup marks the device as not missed, e.g. after a failed notification
+func (this *Controller) resetMissedWakeup(ctx context.Context, device SleepingDevice) (err error) {
+	_, span := this.startMongoSpan(ctx, "resetMissedWakeup", this.config.DutyCycleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDutyCycleCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device_id": device.DeviceId, "sleeping_since": device.SleepingSince, "missed": true}, bson.M{"$set": bson.M{"missed": false}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// CheckDutyCycles handles sleeping devices which missed their expected reconnect:
+// the device state changes from sleeping to offline and the owner is notified (respecting maintenance windows)
+func (this *Controller) CheckDutyCycles(ctx context.Context) error {
+	for ctx.Err() == nil {
+		device, found, err := this.claimMissedWakeup(time.Now())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+		err = this.handleMissedWakeup(ctx, device)
+		if err != nil {
+			log.Println("ERROR: handleMissedWakeup()", device.DeviceId, err)
+		}
+	}
+	return nil
+}
+
+func (this *Controller) handleMissedWakeup(ctx context.Context, device SleepingDevice) error {
+	devicelog := model.DeviceLog{
+		Id:                  device.DeviceId,
+		Connected:           false,
+		Time:                device.WakeDeadline,
+		DeviceOwner:         device.Owner,
+		DeviceName:          device.DeviceName,
+		Locale:              device.Locale,
+		NotificationChannel: device.Channel,
+		Metadata:            device.Metadata,
+	}
+	action := this.getMaintenanceAction(ctx, this.getDeviceMaintenanceTargets(ctx, devicelog))
+	if action == model.MaintenanceActionDefer {
+		//retried after the lease
+		return nil
+	}
+	ok, err := this.markMissedWakeup(ctx, device)
+	if err != nil || !ok {
+		return err
+	}
+	//the device is marked before the side effects, so other instances do not handle it concurrently;
+	//failed steps reset the mark and are retried after the lease
+	outcome, err := this.notifyMissedWakeup(ctx, devicelog, device, action)
+	if err != nil {
+		resetErr := this.resetMissedWakeup(ctx, device)
+		if resetErr != nil {
+			log.Println("ERROR: resetMissedWakeup()", device.DeviceId, resetErr)
+		}
+		return err
+	}
+	if outcome != DeliverySent && outcome != DeliveryQueued && outcome != DeliveryDelayed {
+		return nil
+	}
+	//enables reconnect notifications; the notification is already sent, so a failure is not retried
+	return this.setDeviceOfflineNotificationInfos(ctx, DeviceOfflineNotificationInfo{
+		DeviceId:     device.DeviceId,
+		OfflineSince: device.SleepingSince,
+		Notified:     true,
+	})
+}
+
+// notifyMissedWakeup changes the state of the device to offline and notifies the recipients.
+// the history is written before the state, so a retry after a failed step writes the same history point again instead of skipping it.
+func (this *Controller) notifyMissedWakeup(ctx context.Context, devicelog model.DeviceLog, device SleepingDevice, action string) (outcome DeliveryOutcome, err error) {
+	err = this.logDeviceHistory(ctx, devicelog, false)
+	if err != nil {
+		return outcome, err
+	}
+	_, err = this.setDeviceState(ctx, devicelog, false)
+	if err != nil {
+		return outcome, err
+	}
+	if action == model.MaintenanceActionSuppress {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionSuppressed, "missed wakeup; maintenance window", "", device.SleepingSince)
+		return DeliveryNoRecipients, nil
+	}
+	if device.Owner == "" {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionMissingOwner, "missed wakeup; device has no owner", "", device.SleepingSince)
+		return DeliveryNoRecipients, nil
+	}
+	outcome, err = this.sendMissedWakeupNotification(ctx, devicelog, device)
+	if err != nil {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionFailed, "missed wakeup: "+err.Error(), "", device.SleepingSince)
+		return outcome, err
+	}
+	decision, reason := outcome.AuditDecision("missed wakeup")
+	if decision != model.NotificationDecisionSent {
+		reason = "missed wakeup; " + reason
+	}
+	this.auditNotification(ctx, devicelog, decision, reason, "", device.SleepingSince)
+	return outcome, nil
+}
+
+func (this *Controller) sendMissedWakeupNotification(ctx context.Context, devicelog model.DeviceLog, device SleepingDevice) (DeliveryOutcome, error) {
+	if this.config.Debug {
+		log.Printf("DEBUG: send missed wakeup notification for %#v\n", device)
+	}
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.OfflineSince = time.Unix(device.SleepingSince, 0)
+	data.Duration = device.WakeDeadline.Sub(data.OfflineSince).Round(this.roundTime).String()
+	return this.deliverResourceNotification(ctx, this.deviceResource(devicelog), DeviceMissedWakeupTemplate, "device_offline", data, 0)
+}
+
+func (this *Controller) startDutyCycleCheck(ctx context.Context) {
+	ticker := time.NewTicker(parseDurationWithDefault(this.config.DutyCycleCheckInterval, 10*time.Second))
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := this.CheckDutyCycles(ctx)
+				if err != nil {
+					log.Println("ERROR: CheckDutyCycles()", err)
+				}
+			}
+		}
+	}()
+}
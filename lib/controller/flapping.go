@@ -0,0 +1,193 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlappingRule alerts if a device disconnects more than Disconnects times within Window
+type FlappingRule struct {
+	Disconnects int
+	Window      time.Duration
+}
+
+// ParseFlappingRule parses a monitor_flapping value like "10/1h" (more than 10 disconnects within an hour).
+// the window is parsed by ParseMonitorDuration; values in MonitorOffKeywords result in ok == false.
+func ParseFlappingRule(value string) (result FlappingRule, ok bool, err error) {
+	value = strings.TrimSpace(value)
+	if IsMonitorOff(value) {
+		return result, false, nil
+	}
+	disconnects, window, found := strings.Cut(value, "/")
+	if !found {
+		return result, false, errors.New("invalid flapping rule: expected <disconnects>/<window>")
+	}
+	result.Disconnects, err = strconv.Atoi(strings.TrimSpace(disconnects))
+	if err != nil || result.Disconnects <= 0 {
+		return result, false, errors.New("invalid flapping rule: disconnects must be a positive integer")
+	}
+	result.Window, err = ParseMonitorDuration(window)
+	if err != nil {
+		return result, false, fmt.Errorf("invalid flapping window: %w", err)
+	}
+	if result.Window <= 0 {
+		return result, false, errors.New("invalid flapping window: must be positive")
+	}
+	return result, true, nil
+}
+
+// Exceeded checks if the disconnects within the window before now exceed the rule
+func (this FlappingRule) Exceeded(disconnects []time.Time, now time.Time) (count int, exceeded bool) {
+	for _, t := range disconnects {
+		if now.Sub(t) <= this.Window {
+			count++
+		}
+	}
+	return count, count > this.Disconnects
+}
+
+// FlappingDevice stores the latest disconnects of a device; NotifiedAt prevents more than one alert per window
+type FlappingDevice struct {
+	DeviceId    string      `json:"device_id" bson:"device_id"`
+	Disconnects []time.Time `json:"disconnects" bson:"disconnects"`
+	NotifiedAt  time.Time   `json:"notified_at" bson:"notified_at"`
+}
+
+func (this *Controller) flappingEnabled() bool {
+	return this.config.FlappingCollection != "" && this.config.FlappingCollection != "-"
+}
+
+func (this *Controller) getFlappingCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.FlappingCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"device_id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getFlappingCollection device_id index: ", err)
+	}
+	return
+}
+
+// handleFlapping records disconnect transitions of devices with monitor_flapping rule and notifies if the rule is exceeded.
+// disconnects of sleeping devices are expected and ignored.
+func (this *Controller) handleFlapping(ctx context.Context, devicelog model.DeviceLog, sleeping bool) {
+	if !this.flappingEnabled() || devicelog.Connected || sleeping || devicelog.MonitorFlapping == "" {
+		return
+	}
+	rule, ok, err := ParseFlappingRule(devicelog.MonitorFlapping)
+	if err != nil {
+		log.Println("WARNING: invalid monitor_flapping value", devicelog.Id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	device, err := this.addFlappingDisconnect(ctx, devicelog.Id, devicelog.Time, rule)
+	if err != nil {
+		log.Println("ERROR: addFlappingDisconnect()", err)
+		return
+	}
+	count, exceeded := rule.Exceeded(device.Disconnects, devicelog.Time)
+	if !exceeded || devicelog.Time.Sub(device.NotifiedAt) < rule.Window || devicelog.DeviceOwner == "" || time.Since(devicelog.Time) > time.Hour {
+		return
+	}
+	claimed, err := this.claimFlappingNotification(ctx, device, devicelog.Time)
+	if err != nil {
+		log.Println("ERROR: claimFlappingNotification()", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	reason := fmt.Sprintf("%v disconnects within %v", count, rule.Window)
+	action := this.getMaintenanceAction(ctx, this.getDeviceMaintenanceTargets(ctx, devicelog))
+	if action != "" {
+		//flapping during maintenance is expected
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionSuppressed, "flapping: "+reason+"; maintenance window", "", 0)
+		return
+	}
+	err = this.sendFlappingNotification(ctx, devicelog, count, rule)
+	if err != nil {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionFailed, "flapping: "+err.Error(), "", 0)
+		log.Println("ERROR: sendFlappingNotification()", err)
+		return
+	}
+	this.auditNotification(ctx, devicelog, model.NotificationDecisionSent, "flapping: "+reason, "", 0)
+}
+
+// addFlappingDisconnect stores the disconnect and keeps the latest rule.Disconnects + 1 disconnects, which are enough to evaluate the rule
+func (this *Controller) addFlappingDisconnect(ctx context.Context, deviceId string, t time.Time, rule FlappingRule) (result FlappingDevice, err error) {
+	_, span := this.startMongoSpan(ctx, "addFlappingDisconnect", this.config.FlappingCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getFlappingCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{"device_id": deviceId}).Apply(mgo.Change{
+		Update: bson.M{
+			"$push": bson.M{"disconnects": bson.M{
+				"$each":  []time.Time{t},
+				"$sort":  1,
+				"$slice": -(rule.Disconnects + 1),
+			}},
+			"$setOnInsert": bson.M{"notified_at": time.Time{}},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &result)
+	return result, err
+}
+
+// claimFlappingNotification returns false if another instance notified since device was loaded
+func (this *Controller) claimFlappingNotification(ctx context.Context, device FlappingDevice, t time.Time) (ok bool, err error) {
+	_, span := this.startMongoSpan(ctx, "claimFlappingNotification", this.config.FlappingCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getFlappingCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device_id": device.DeviceId, "notified_at": device.NotifiedAt}, bson.M{"$set": bson.M{"notified_at": t}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (this *Controller) removeFlappingDevice(ctx context.Context, deviceId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeFlappingDevice", this.config.FlappingCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getFlappingCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"device_id": deviceId})
+	return err
+}
+
+func (this *Controller) sendFlappingNotification(ctx context.Context, devicelog model.DeviceLog, count int, rule FlappingRule) error {
+	if this.config.Debug {
+		log.Printf("DEBUG: send flapping notification for %#v\n", devicelog)
+	}
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.Disconnects = count
+	data.Duration = rule.Window.String()
+	return this.sendResourceNotification(ctx, this.deviceResource(devicelog), DeviceFlappingTemplate, "device_flapping", data, 0)
+}
@@ -17,16 +17,34 @@
 package controller
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
-func (this *Controller) UpdateHub(command model.HubCommand) error {
+func (this *Controller) UpdateHub(ctx context.Context, command model.HubCommand) error {
 	if command.Command == "DELETE" {
-		err := this.deleteGatewayLog(command.Id)
+		err := this.deleteGatewayLog(ctx, command.Id)
 		if err != nil {
 			return err
 		}
-		return this.deleteHubState(command.Id)
+		err = this.deleteHubState(ctx, command.Id)
+		if err != nil {
+			return err
+		}
+		err = this.removeHubOfflineNotificationInfos(ctx, command.Id)
+		if err != nil {
+			return err
+		}
+		if this.rulesEnabled() {
+			err = this.removeRuleState(ctx, RuleTypeHub, command.Id)
+			if err != nil {
+				return err
+			}
+		}
+		return this.deleteHubMetadata(ctx, command.Id)
+	}
+	if command.Command == "PUT" {
+		return this.setHubMetadata(ctx, command)
 	}
 	return nil
 }
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+)
+
+const MonitorConnectionStateAttribute = "monitor_connection_state"
+const LocaleAttribute = "locale"
+const NotificationChannelAttribute = "notification_channel"
+
+// HubMetadata caches the hub information of the hubs topic needed for hub notifications
+type HubMetadata struct {
+	HubId                  string   `json:"hub_id" bson:"hub_id"`
+	Name                   string   `json:"name" bson:"name"`
+	OwnerId                string   `json:"owner_id" bson:"owner_id"`
+	DeviceIds              []string `json:"device_ids" bson:"device_ids"`
+	MonitorConnectionState string   `json:"monitor_connection_state" bson:"monitor_connection_state"`
+	Locale                 string   `json:"locale" bson:"locale"`
+	NotificationChannel    string   `json:"notification_channel" bson:"notification_channel"`
+}
+
+// hubMetadataEnabled checks HubMetadataCollection; without hub metadata, devices have no known hub and hubs no names, owners or attributes from the hubs topic
+func (this *Controller) hubMetadataEnabled() bool {
+	return this.config.HubMetadataCollection != "" && this.config.HubMetadataCollection != "-"
+}
+
+func (this *Controller) getHubMetadataCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.HubMetadataCollection)
+	err := collection.EnsureIndexKey("hub_id")
+	if err != nil {
+		log.Fatal("error on getHubMetadataCollection hub_id index: ", err)
+	}
+	err = collection.EnsureIndexKey("device_ids")
+	if err != nil {
+		log.Fatal("error on getHubMetadataCollection device_ids index: ", err)
+	}
+	return
+}
+
+func (this *Controller) setHubMetadata(ctx context.Context, command model.HubCommand) (err error) {
+	if !this.hubMetadataEnabled() {
+		return nil
+	}
+	_, span := this.startMongoSpan(ctx, "setHubMetadata", this.config.HubMetadataCollection)
+	defer func() { tracing.End(span, err) }()
+	metadata := HubMetadata{
+		HubId:     command.Id,
+		Name:      command.Hub.Name,
+		OwnerId:   command.Hub.OwnerId,
+		DeviceIds: command.Hub.DeviceIds,
+	}
+	if metadata.OwnerId == "" {
+		metadata.OwnerId = command.Owner
+	}
+	metadata.MonitorConnectionState, _ = model.GetAttribute(command.Hub.Attributes, MonitorConnectionStateAttribute)
+	metadata.Locale, _ = model.GetAttribute(command.Hub.Attributes, LocaleAttribute)
+	metadata.NotificationChannel, _ = model.GetAttribute(command.Hub.Attributes, NotificationChannelAttribute)
+	session, collection := this.getHubMetadataCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"hub_id": metadata.HubId}, metadata)
+	return err
+}
+
+func (this *Controller) getHubMetadata(ctx context.Context, hubId string) (metadata HubMetadata, found bool, err error) {
+	if !this.hubMetadataEnabled() {
+		return metadata, false, nil
+	}
+	_, span := this.startMongoSpan(ctx, "getHubMetadata", this.config.HubMetadataCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubMetadataCollection()
+	defer session.Close()
+	list := []HubMetadata{}
+	err = collection.Find(bson.M{"hub_id": hubId}).Limit(1).All(&list)
+	if err != nil || len(list) == 0 {
+		return metadata, false, err
+	}
+	return list[0], true, nil
+}
+
+func (this *Controller) getHubMetadataByDevice(ctx context.Context, deviceId string) (metadata HubMetadata, found bool, err error) {
+	if !this.hubMetadataEnabled() {
+		return metadata, false, nil
+	}
+	_, span := this.startMongoSpan(ctx, "getHubMetadataByDevice", this.config.HubMetadataCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubMetadataCollection()
+	defer session.Close()
+	list := []HubMetadata{}
+	err = collection.Find(bson.M{"device_ids": deviceId}).Limit(1).All(&list)
+	if err != nil || len(list) == 0 {
+		return metadata, false, err
+	}
+	return list[0], true, nil
+}
+
+func (this *Controller) deleteHubMetadata(ctx context.Context, hubId string) (err error) {
+	if !this.hubMetadataEnabled() {
+		return nil
+	}
+	_, span := this.startMongoSpan(ctx, "deleteHubMetadata", this.config.HubMetadataCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubMetadataCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"hub_id": hubId})
+	return err
+}
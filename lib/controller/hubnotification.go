@@ -0,0 +1,258 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+// HubOfflineInfo is the information needed to notify about an offline hub.
+// values of the hub log take precedence over the cached hub metadata.
+type HubOfflineInfo struct {
+	HubId                  string
+	HubName                string
+	HubOwner               string
+	MonitorConnectionState string
+	Locale                 string
+	NotificationChannel    string
+	DeviceIds              []string
+	Metadata               map[string]string
+}
+
+func (this *Controller) hubNotificationsEnabled() bool {
+	return this.config.HubOfflineNotificationInfoCollection != "" && this.config.HubOfflineNotificationInfoCollection != "-"
+}
+
+func (this *Controller) handleHubNotifications(ctx context.Context, hublog model.HubLog) {
+	if !this.hubNotificationsEnabled() {
+		return
+	}
+	if hublog.Connected {
+		err := this.removeHubOfflineNotificationInfos(ctx, hublog.Id)
+		if err != nil {
+			log.Println("ERROR: removeHubOfflineNotificationInfos()", err)
+		}
+		return
+	}
+	info, exists, err := this.getHubOfflineNotificationInfos(ctx, hublog.Id)
+	if err != nil {
+		log.Println("ERROR: getHubOfflineNotificationInfos()", err)
+		return
+	}
+	if !exists {
+		err = this.setHubOfflineNotificationInfos(ctx, HubOfflineNotificationInfo{
+			HubId:        hublog.Id,
+			OfflineSince: hublog.Time.Unix(),
+			Notified:     false,
+		})
+		if err != nil {
+			log.Println("ERROR: setHubOfflineNotificationInfos()", err)
+		}
+		return
+	}
+	hub, err := this.getHubOfflineInfo(ctx, hublog)
+	if err != nil {
+		log.Println("ERROR: getHubOfflineInfo()", err)
+		return
+	}
+	if hub.MonitorConnectionState == "" || hub.HubOwner == "" {
+		return
+	}
+	levels, err := ParseMonitorPolicy(hub.MonitorConnectionState)
+	if err != nil {
+		this.sendHubMonitorParseErrorNotification(ctx, hub, err)
+		log.Println("ERROR: ParseMonitorPolicy()", err)
+		return
+	}
+	if len(levels) == 0 {
+		//monitoring is turned off
+		return
+	}
+	if info.Notified && len(info.NotifiedLevels) == 0 {
+		info.NotifiedLevels = []string{levels[0].Key()}
+	}
+	since := time.Since(time.Unix(info.OfflineSince, 0))
+	level, exceeded, ok := NextMonitorLevel(levels, since, info.NotifiedLevels)
+	if !ok {
+		return
+	}
+	action := this.getMaintenanceAction(ctx, map[string]string{model.MaintenanceScopeHub: hub.HubId, model.MaintenanceScopeOwner: hub.HubOwner})
+	if action == model.MaintenanceActionDefer {
+		return
+	}
+	if action == model.MaintenanceActionSuppress || this.coveredByOutage(ctx, hub.HubId) {
+		info.NotifiedLevels = mergeNotifiedLevels(info.NotifiedLevels, exceeded)
+		err = this.setHubOfflineNotificationInfos(ctx, info)
+		if err != nil {
+			log.Println("ERROR: unable to update hub info with suppressed levels", err)
+		}
+		return
+	}
+	unreachable, err := this.countUnreachableDevices(ctx, hub.DeviceIds)
+	if err != nil {
+		log.Println("ERROR: countUnreachableDevices()", err)
+		return
+	}
+	err = this.sendHubOfflineNotification(ctx, hub, info, since, unreachable, level)
+	if err != nil {
+		log.Println("ERROR: unable to send hub notification", err)
+		return
+	}
+	info.Notified = true
+	info.NotifiedLevels = mergeNotifiedLevels(info.NotifiedLevels, exceeded)
+	err = this.setHubOfflineNotificationInfos(ctx, info)
+	if err != nil {
+		log.Println("ERROR: unable to update hub info with notified flag", err)
+	}
+}
+
+func (this *Controller) getHubOfflineInfo(ctx context.Context, hublog model.HubLog) (result HubOfflineInfo, err error) {
+	result = HubOfflineInfo{
+		HubId:                  hublog.Id,
+		HubName:                hublog.HubName,
+		HubOwner:               hublog.HubOwner,
+		MonitorConnectionState: hublog.MonitorConnectionState,
+		Locale:                 hublog.Locale,
+		NotificationChannel:    hublog.NotificationChannel,
+		Metadata:               hublog.Metadata,
+	}
+	metadata, found, err := this.getHubMetadata(ctx, hublog.Id)
+	if err != nil || !found {
+		return result, err
+	}
+	if result.HubName == "" {
+		result.HubName = metadata.Name
+	}
+	if result.HubOwner == "" {
+		result.HubOwner = metadata.OwnerId
+	}
+	if result.MonitorConnectionState == "" {
+		result.MonitorConnectionState = metadata.MonitorConnectionState
+	}
+	if result.Locale == "" {
+		result.Locale = metadata.Locale
+	}
+	if result.NotificationChannel == "" {
+		result.NotificationChannel = metadata.NotificationChannel
+	}
+	result.DeviceIds = metadata.DeviceIds
+	return result, nil
+}
+
+// countUnreachableDevices counts the devices with an offline state; devices without state and sleeping devices (duty cycle) are not counted
+func (this *Controller) countUnreachableDevices(ctx context.Context, deviceIds []string) (count int, err error) {
+	if len(deviceIds) == 0 {
+		return 0, nil
+	}
+	_, span := this.startMongoSpan(ctx, "countUnreachableDevices", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDeviceStateCollection()
+	defer session.Close()
+	return collection.Find(bson.M{"device": bson.M{"$in": deviceIds}, "online": false, "sleeping": bson.M{"$ne": true}}).Count()
+}
+
+func (this *Controller) getHubOfflineNotificationInfoCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.HubOfflineNotificationInfoCollection)
+	err := collection.EnsureIndexKey("hub_id")
+	if err != nil {
+		log.Fatal("error on getHubOfflineNotificationInfoCollection hub index: ", err)
+	}
+	return
+}
+
+type HubOfflineNotificationInfo struct {
+	HubId          string   `json:"hub_id" bson:"hub_id"`
+	OfflineSince   int64    `json:"offline_since" bson:"offline_since"`
+	Notified       bool     `json:"notified" bson:"notified"`
+	NotifiedLevels []string `json:"notified_levels" bson:"notified_levels"`
+}
+
+func (this *Controller) removeHubOfflineNotificationInfos(ctx context.Context, hubId string) (err error) {
+	if !this.hubNotificationsEnabled() {
+		return nil
+	}
+	_, span := this.startMongoSpan(ctx, "removeHubOfflineNotificationInfos", this.config.HubOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubOfflineNotificationInfoCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"hub_id": hubId})
+	return err
+}
+
+func (this *Controller) getHubOfflineNotificationInfos(ctx context.Context, hubId string) (info HubOfflineNotificationInfo, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getHubOfflineNotificationInfos", this.config.HubOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubOfflineNotificationInfoCollection()
+	defer session.Close()
+	list := []HubOfflineNotificationInfo{}
+	err = collection.Find(bson.M{"hub_id": hubId}).Limit(1).All(&list)
+	if err != nil || len(list) == 0 {
+		return info, false, err
+	}
+	return list[0], true, nil
+}
+
+func (this *Controller) setHubOfflineNotificationInfos(ctx context.Context, info HubOfflineNotificationInfo) (err error) {
+	_, span := this.startMongoSpan(ctx, "setHubOfflineNotificationInfos", this.config.HubOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubOfflineNotificationInfoCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"hub_id": info.HubId}, info)
+	return err
+}
+
+func (this *Controller) sendHubOfflineNotification(ctx context.Context, hub HubOfflineInfo, info HubOfflineNotificationInfo, since time.Duration, unreachableDevices int, level MonitorLevel) error {
+	if this.config.Debug {
+		log.Printf("DEBUG: send hub notification for %#v\n", hub)
+	}
+	data := getHubTemplateData(hub)
+	data.Duration = since.Round(this.roundTime).String()
+	data.OfflineSince = time.Unix(info.OfflineSince, 0)
+	data.Severity = level.Severity
+	data.UnreachableDevices = unreachableDevices
+	return this.sendResourceNotification(ctx, this.hubResource(hub), HubOfflineTemplate, withDefault(level.Topic, "gateway_offline"), data, 0)
+}
+
+func (this *Controller) sendHubMonitorParseErrorNotification(ctx context.Context, hub HubOfflineInfo, err error) {
+	if this.config.Debug {
+		log.Printf("DEBUG: send hub parse error (%v) notification for %#v\n", err.Error(), hub)
+	}
+	data := getHubTemplateData(hub)
+	data.Error = err.Error()
+	err = this.sendTemplateNotification(ctx, HubMonitorErrorTemplate, hub.Locale, hub.NotificationChannel, "gateway_offline", data, 24*time.Hour)
+	if err != nil {
+		log.Println("ERROR: sendHubMonitorParseErrorNotification()", err)
+	}
+}
+
+func getHubTemplateData(hub HubOfflineInfo) NotificationTemplateData {
+	return NotificationTemplateData{
+		HubId:    hub.HubId,
+		HubName:  hub.HubName,
+		Owner:    hub.HubOwner,
+		Time:     time.Now(),
+		Devices:  len(hub.DeviceIds),
+		Metadata: hub.Metadata,
+	}
+}
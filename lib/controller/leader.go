@@ -0,0 +1,136 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+// LeaderLease is held by the instance running the background jobs until Expires
+type LeaderLease struct {
+	Id      string    `json:"id" bson:"_id"`
+	Holder  string    `json:"holder" bson:"holder"`
+	Expires time.Time `json:"expires" bson:"expires"`
+}
+
+const backgroundJobsLease = "background_jobs"
+
+func (this *Controller) leaderElectionEnabled() bool {
+	return this.config.LeaderElectionCollection != "" && this.config.LeaderElectionCollection != "-"
+}
+
+func (this *Controller) getLeaderCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.LeaderElectionCollection)
+	return
+}
+
+// IsLeader checks if this instance runs the background jobs; without leader election every instance runs them
+func (this *Controller) IsLeader() bool {
+	if !this.leaderElectionEnabled() {
+		return true
+	}
+	jobCtx := this.leaderJobs.Load()
+	return jobCtx != nil && (*jobCtx).Err() == nil
+}
+
+// acquireLeadership takes or renews the lease, if it is free, expired or already held by this instance.
+// an other valid holder lets the upsert fail with a duplicate key error.
+func (this *Controller) acquireLeadership(ctx context.Context, now time.Time) (leader bool, err error) {
+	_, span := this.startMongoSpan(ctx, "acquireLeadership", this.config.LeaderElectionCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getLeaderCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{
+		"_id": backgroundJobsLease,
+		"$or": []bson.M{{"holder": this.instanceId}, {"expires": bson.M{"$lte": now}}},
+	}, bson.M{"$set": bson.M{"holder": this.instanceId, "expires": now.Add(this.leaderLease)}})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseLeadership removes the lease of this instance, so that an other instance can take over without waiting for the lease to expire
+func (this *Controller) releaseLeadership(ctx context.Context) (err error) {
+	_, span := this.startMongoSpan(ctx, "releaseLeadership", this.config.LeaderElectionCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getLeaderCollection()
+	defer session.Close()
+	err = collection.Remove(bson.M{"_id": backgroundJobsLease, "holder": this.instanceId})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// startLeaderElection tries to acquire or renew the lease every third of LeaderElectionLease and runs jobs while this instance holds it.
+// the context of jobs is canceled when a renewal fails or the lease expires without renewal; the lease is released when ctx is done.
+func (this *Controller) startLeaderElection(ctx context.Context, jobs func(ctx context.Context)) {
+	ticker := time.NewTicker(max(this.leaderLease/3, 100*time.Millisecond))
+	go func() {
+		defer ticker.Stop()
+		var jobCtx context.Context
+		var stopJobs context.CancelFunc
+		var expiry *time.Timer
+		for {
+			now := time.Now()
+			leader, err := this.acquireLeadership(ctx, now)
+			if err != nil {
+				log.Println("ERROR: unable to acquire leadership", err)
+			}
+			running := jobCtx != nil && jobCtx.Err() == nil
+			switch {
+			case leader && running:
+				expiry.Reset(time.Until(now.Add(this.leaderLease)))
+			case leader:
+				log.Println("acquired leadership; start background jobs", this.instanceId)
+				jobCtx, stopJobs = context.WithCancel(ctx)
+				expiry = time.AfterFunc(time.Until(now.Add(this.leaderLease)), stopJobs)
+				current := jobCtx
+				this.leaderJobs.Store(&current)
+				jobs(jobCtx)
+			case running:
+				log.Println("WARNING: lost leadership; stop background jobs", this.instanceId)
+				expiry.Stop()
+				stopJobs()
+			}
+			select {
+			case <-ctx.Done():
+				if jobCtx != nil {
+					expiry.Stop()
+					stopJobs()
+					err = this.releaseLeadership(context.Background())
+					if err != nil {
+						log.Println("ERROR: unable to release leadership", err)
+					}
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
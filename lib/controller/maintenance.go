@@ -0,0 +1,159 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+func (this *Controller) getMaintenanceWindowCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.MaintenanceWindowCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getMaintenanceWindowCollection id index: ", err)
+	}
+	err = collection.EnsureIndexKey("scope", "target_id")
+	if err != nil {
+		log.Fatal("error on getMaintenanceWindowCollection scope index: ", err)
+	}
+	return
+}
+
+// ListMaintenanceWindows returns the maintenance windows matching scope and targetId; empty values match every window
+func (this *Controller) ListMaintenanceWindows(ctx context.Context, scope string, targetId string) (result []model.MaintenanceWindow, err error) {
+	_, span := this.startMongoSpan(ctx, "ListMaintenanceWindows", this.config.MaintenanceWindowCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getMaintenanceWindowCollection()
+	defer session.Close()
+	query := bson.M{}
+	if scope != "" {
+		query["scope"] = scope
+	}
+	if targetId != "" {
+		query["target_id"] = targetId
+	}
+	result = []model.MaintenanceWindow{}
+	err = collection.Find(query).Sort("id").All(&result)
+	return result, err
+}
+
+func (this *Controller) GetMaintenanceWindow(ctx context.Context, id string) (result model.MaintenanceWindow, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "GetMaintenanceWindow", this.config.MaintenanceWindowCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getMaintenanceWindowCollection()
+	defer session.Close()
+	list := []model.MaintenanceWindow{}
+	err = collection.Find(bson.M{"id": id}).Limit(1).All(&list)
+	if err != nil || len(list) == 0 {
+		return result, false, err
+	}
+	return list[0], true, nil
+}
+
+func (this *Controller) SetMaintenanceWindow(ctx context.Context, window model.MaintenanceWindow) (err error) {
+	_, span := this.startMongoSpan(ctx, "SetMaintenanceWindow", this.config.MaintenanceWindowCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getMaintenanceWindowCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": window.Id}, window)
+	return err
+}
+
+func (this *Controller) DeleteMaintenanceWindow(ctx context.Context, id string) (err error) {
+	_, span := this.startMongoSpan(ctx, "DeleteMaintenanceWindow", this.config.MaintenanceWindowCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getMaintenanceWindowCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"id": id})
+	return err
+}
+
+// getActiveMaintenanceWindow returns a window active at t for one of the targets (scope -> target id).
+// deferring windows take precedence, to not lose notifications if suppressing and deferring windows overlap.
+func (this *Controller) getActiveMaintenanceWindow(ctx context.Context, targets map[string]string, t time.Time) (result model.MaintenanceWindow, active bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getActiveMaintenanceWindow", this.config.MaintenanceWindowCollection)
+	defer func() { tracing.End(span, err) }()
+	or := []bson.M{}
+	for scope, targetId := range targets {
+		if targetId != "" {
+			or = append(or, bson.M{"scope": scope, "target_id": targetId})
+		}
+	}
+	if len(or) == 0 {
+		return result, false, nil
+	}
+	session, collection := this.getMaintenanceWindowCollection()
+	defer session.Close()
+	list := []model.MaintenanceWindow{}
+	err = collection.Find(bson.M{"$or": or}).Sort("id").All(&list)
+	if err != nil {
+		return result, false, err
+	}
+	for _, window := range list {
+		if !window.Active(t) {
+			continue
+		}
+		if !active || window.GetAction() == model.MaintenanceActionDefer {
+			result = window
+			active = true
+		}
+	}
+	return result, active, nil
+}
+
+func (this *Controller) maintenanceEnabled() bool {
+	return this.config.MaintenanceWindowCollection != "" && this.config.MaintenanceWindowCollection != "-"
+}
+
+// getMaintenanceAction returns the action of the maintenance window active for one of the targets or "" if none is active.
+// errors are logged and result in "" to not lose notifications.
+func (this *Controller) getMaintenanceAction(ctx context.Context, targets map[string]string) string {
+	if !this.maintenanceEnabled() {
+		return ""
+	}
+	window, active, err := this.getActiveMaintenanceWindow(ctx, targets, time.Now())
+	if err != nil {
+		log.Println("ERROR: getActiveMaintenanceWindow()", err)
+		return ""
+	}
+	if !active {
+		return ""
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: maintenance window %v (%v) active for %#v\n", window.Id, window.GetAction(), targets)
+	}
+	return window.GetAction()
+}
+
+func (this *Controller) getDeviceMaintenanceTargets(ctx context.Context, devicelog model.DeviceLog) map[string]string {
+	targets := map[string]string{model.MaintenanceScopeDevice: devicelog.Id, model.MaintenanceScopeOwner: devicelog.DeviceOwner}
+	hub, found, err := this.getHubMetadataByDevice(ctx, devicelog.Id)
+	if err != nil {
+		log.Println("WARNING: unable to get hub of device for maintenance windows", devicelog.Id, err)
+	}
+	if found {
+		targets[model.MaintenanceScopeHub] = hub.HubId
+	}
+	return targets
+}
@@ -17,8 +17,11 @@
 package controller
 
 import (
+	"context"
 	"log"
 
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/mgo.v2"
 )
 
@@ -34,6 +37,15 @@ func (this *Controller) getMongoDb() *mgo.Session {
 	return this.mongoDbInstance.Copy()
 }
 
+func (this *Controller) startMongoSpan(ctx context.Context, operation string, collection string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "mongodb "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemMongoDB,
+		semconv.DBNamespace(this.config.MongoTable),
+		semconv.DBCollectionName(collection),
+		semconv.DBOperationName(operation),
+	))
+}
+
 func (this *Controller) getDeviceStateCollection() (session *mgo.Session, collection *mgo.Collection) {
 	session = this.getMongoDb()
 	collection = session.DB(this.config.MongoTable).C(this.config.DeviceStateCollection)
@@ -41,6 +53,10 @@ func (this *Controller) getDeviceStateCollection() (session *mgo.Session, collec
 	if err != nil {
 		log.Fatal("error on getDeviceCollection device index: ", err)
 	}
+	err = collection.EnsureIndexKey("owner")
+	if err != nil {
+		log.Fatal("error on getDeviceCollection owner index: ", err)
+	}
 	return
 }
 
@@ -55,9 +71,11 @@ func (this *Controller) getHubStateCollection() (session *mgo.Session, collectio
 }
 
 type DeviceState struct {
-	Device string `json:"device,omitempty" bson:"device,omitempty"`
-	Online bool   `json:"online" bson:"online"`
-	Since  int64  `json:"since" bson:"since"`
+	Device   string `json:"device,omitempty" bson:"device,omitempty"`
+	Online   bool   `json:"online" bson:"online"`
+	Sleeping bool   `json:"sleeping" bson:"sleeping"`     //offline as expected by the duty cycle of the device
+	Owner    string `json:"owner,omitempty" bson:"owner"` //always stored to detect states written before the owner was recorded
+	Since    int64  `json:"since" bson:"since"`
 }
 
 type HubState struct {
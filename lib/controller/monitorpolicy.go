@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MonitorLevel is a threshold of a monitor_connection_state policy.
+// Severity and Topic are optional and default to the notification type defaults.
+type MonitorLevel struct {
+	After    time.Duration `json:"-"`
+	Severity string        `json:"severity,omitempty"`
+	Topic    string        `json:"topic,omitempty"`
+}
+
+// Key identifies the level in the notified levels of offline notification infos
+func (this MonitorLevel) Key() string {
+	return this.After.String()
+}
+
+// ParseMonitorPolicy parses a monitor_connection_state value.
+// allowed are a single duration ("10m"), a comma separated list of durations ("10m,1h,1d")
+// or a json list of levels ([{"after":"10m"},{"after":"1h","severity":"critical","topic":"device_offline_critical"}]).
+// durations are parsed by ParseMonitorDuration. the resulting levels are sorted by their duration.
+// values in MonitorOffKeywords result in an empty policy.
+func ParseMonitorPolicy(value string) (levels []MonitorLevel, err error) {
+	value = strings.TrimSpace(value)
+	if IsMonitorOff(value) {
+		return nil, nil
+	}
+	if strings.HasPrefix(value, "[") {
+		levels, err = parseJsonMonitorPolicy(value)
+	} else {
+		for _, part := range splitMonitorDurations(value) {
+			after, err := ParseMonitorDuration(part)
+			if err != nil {
+				return nil, err
+			}
+			levels = append(levels, MonitorLevel{After: after})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, errors.New("empty monitor policy; " + MonitorFormatDescription)
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].After < levels[j].After
+	})
+	return levels, nil
+}
+
+func parseJsonMonitorPolicy(value string) (levels []MonitorLevel, err error) {
+	temp := []struct {
+		After    string `json:"after"`
+		Severity string `json:"severity"`
+		Topic    string `json:"topic"`
+	}{}
+	err = json.Unmarshal([]byte(value), &temp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json policy: %w; %v", err, MonitorFormatDescription)
+	}
+	for _, element := range temp {
+		after, err := ParseMonitorDuration(element.After)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, MonitorLevel{After: after, Severity: element.Severity, Topic: element.Topic})
+	}
+	return levels, nil
+}
+
+// NextMonitorLevel returns the highest level exceeded by since which has not been notified
+// and the keys of all exceeded levels. lower levels which were skipped (e.g. because no message was received in between)
+// are not notified separately.
+func NextMonitorLevel(levels []MonitorLevel, since time.Duration, notified []string) (level MonitorLevel, exceeded []string, ok bool) {
+	for _, l := range levels {
+		if since <= l.After {
+			break
+		}
+		exceeded = append(exceeded, l.Key())
+		if !slices.Contains(notified, l.Key()) {
+			level = l
+			ok = true
+		}
+	}
+	return level, exceeded, ok
+}
+
+func mergeNotifiedLevels(notified []string, exceeded []string) []string {
+	for _, key := range exceeded {
+		if !slices.Contains(notified, key) {
+			notified = append(notified, key)
+		}
+	}
+	return notified
+}
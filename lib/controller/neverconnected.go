@@ -0,0 +1,286 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+const neverConnectedLease = time.Minute
+
+// NeverConnectedDevice is a device with monitor_connection_state which did not send a device log since it was created.
+// the owner is notified once, if the device did not connect until Deadline (CreatedAt + first monitor level).
+type NeverConnectedDevice struct {
+	DeviceId    string    `json:"device_id" bson:"device_id"`
+	DeviceName  string    `json:"device_name" bson:"device_name"`
+	Owner       string    `json:"owner" bson:"owner"`
+	Locale      string    `json:"locale" bson:"locale"`
+	Channel     string    `json:"channel" bson:"channel"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"` //first device event seen by the worker
+	Deadline    time.Time `json:"deadline" bson:"deadline"`
+	LockedUntil time.Time `json:"locked_until" bson:"locked_until"`
+	Notified    bool      `json:"notified" bson:"notified"`
+}
+
+func (this *Controller) neverConnectedEnabled() bool {
+	return this.config.NeverConnectedCollection != "" && this.config.NeverConnectedCollection != "-"
+}
+
+func (this *Controller) getNeverConnectedCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NeverConnectedCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"device_id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getNeverConnectedCollection device_id index: ", err)
+	}
+	err = collection.EnsureIndexKey("notified", "deadline")
+	if err != nil {
+		log.Fatal("error on getNeverConnectedCollection deadline index: ", err)
+	}
+	return
+}
+
+// handleNeverConnected starts the timer of devices with monitor_connection_state without device state.
+// updates of the device keep the creation time; the deadline follows the current monitor_connection_state.
+func (this *Controller) handleNeverConnected(ctx context.Context, command model.DeviceCommand) error {
+	owner := withDefault(command.Device.OwnerId, command.Owner)
+	monitor, _ := model.GetAttribute(command.Device.Attributes, MonitorConnectionStateAttribute)
+	levels, err := ParseMonitorPolicy(monitor)
+	if err != nil {
+		log.Println("WARNING: invalid monitor_connection_state attribute; ignore for never connected devices", command.Id, err)
+		levels = nil
+	}
+	if len(levels) == 0 || owner == "" {
+		return this.removeNeverConnectedDevice(ctx, command.Id)
+	}
+	connected, err := this.hasDeviceState(ctx, command.Id)
+	if err != nil || connected {
+		return err
+	}
+	locale, _ := model.GetAttribute(command.Device.Attributes, LocaleAttribute)
+	channel, _ := model.GetAttribute(command.Device.Attributes, NotificationChannelAttribute)
+	return this.setNeverConnectedDevice(ctx, NeverConnectedDevice{
+		DeviceId:   command.Id,
+		DeviceName: command.Device.Name,
+		Owner:      owner,
+		Locale:     locale,
+		Channel:    channel,
+		CreatedAt:  time.Now(),
+	}, levels[0].After)
+}
+
+func (this *Controller) hasDeviceState(ctx context.Context, deviceId string) (found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "hasDeviceState", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDeviceStateCollection()
+	defer session.Close()
+	count, err := collection.Find(bson.M{"device": deviceId}).Limit(1).Count()
+	return count > 0, err
+}
+
+func (this *Controller) setNeverConnectedDevice(ctx context.Context, device NeverConnectedDevice, after time.Duration) (err error) {
+	_, span := this.startMongoSpan(ctx, "setNeverConnectedDevice", this.config.NeverConnectedCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNeverConnectedCollection()
+	defer session.Close()
+	result := NeverConnectedDevice{}
+	_, err = collection.Find(bson.M{"device_id": device.DeviceId}).Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"device_name": device.DeviceName,
+				"owner":       device.Owner,
+				"locale":      device.Locale,
+				"channel":     device.Channel,
+			},
+			"$setOnInsert": bson.M{
+				"created_at":   device.CreatedAt,
+				"deadline":     device.CreatedAt.Add(after),
+				"locked_until": time.Time{},
+				"notified":     false,
+			},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if deadline := result.CreatedAt.Add(after); !deadline.Equal(result.Deadline) {
+		err = collection.Update(bson.M{"device_id": device.DeviceId}, bson.M{"$set": bson.M{"deadline": deadline}})
+		if errors.Is(err, mgo.ErrNotFound) {
+			//device connected or was deleted meanwhile
+			return nil
+		}
+	}
+	return err
+}
+
+func (this *Controller) removeNeverConnectedDevice(ctx context.Context, deviceId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeNeverConnectedDevice", this.config.NeverConnectedCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNeverConnectedCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"device_id": deviceId})
+	return err
+}
+
+// claimNeverConnected locks a device which did not connect until its deadline
+func (this *Controller) claimNeverConnected(now time.Time) (device NeverConnectedDevice, found bool, err error) {
+	session, collection := this.getNeverConnectedCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{
+		"notified":     false,
+		"deadline":     bson.M{"$lte": now},
+		"locked_until": bson.M{"$lte": now},
+	}).Sort("deadline").Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"locked_until": now.Add(neverConnectedLease)}},
+		ReturnNew: true,
+	}, &device)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return device, false, nil
+	}
+	if err != nil {
+		return device, false, err
+	}
+	return device, true, nil
+}
+
+// markNeverConnectedNotified returns false if the device connected or was deleted since it was claimed
+func (this *Controller) markNeverConnectedNotified(ctx context.Context, device NeverConnectedDevice) (ok bool, err error) {
+	_, span := this.startMongoSpan(ctx, "markNeverConnectedNotified", this.config.NeverConnectedCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNeverConnectedCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device_id": device.DeviceId, "notified": false}, bson.M{"$set": bson.M{"notified": true}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// resetNeverConnectedNotified marks the device as not notified, e.g. after a failed send
+func (this *Controller) resetNeverConnectedNotified(ctx context.Context, device NeverConnectedDevice) (err error) {
+	_, span := this.startMongoSpan(ctx, "resetNeverConnectedNotified", this.config.NeverConnectedCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getNeverConnectedCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"device_id": device.DeviceId, "notified": true}, bson.M{"$set": bson.M{"notified": false}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// CheckNeverConnected notifies the owners of devices which did not connect until their deadline (respecting maintenance windows)
+func (this *Controller) CheckNeverConnected(ctx context.Context) error {
+	for ctx.Err() == nil {
+		device, found, err := this.claimNeverConnected(time.Now())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+		err = this.handleNeverConnectedDeadline(ctx, device)
+		if err != nil {
+			log.Println("ERROR: handleNeverConnectedDeadline()", device.DeviceId, err)
+		}
+	}
+	return nil
+}
+
+func (this *Controller) handleNeverConnectedDeadline(ctx context.Context, device NeverConnectedDevice) error {
+	connected, err := this.hasDeviceState(ctx, device.DeviceId)
+	if err != nil {
+		return err
+	}
+	if connected {
+		return this.removeNeverConnectedDevice(ctx, device.DeviceId)
+	}
+	devicelog := model.DeviceLog{
+		Id:                  device.DeviceId,
+		Connected:           false,
+		Time:                device.Deadline,
+		DeviceOwner:         device.Owner,
+		DeviceName:          device.DeviceName,
+		Locale:              device.Locale,
+		NotificationChannel: device.Channel,
+	}
+	action := this.getMaintenanceAction(ctx, this.getDeviceMaintenanceTargets(ctx, devicelog))
+	if action == model.MaintenanceActionDefer {
+		//retried after the lease
+		return nil
+	}
+	ok, err := this.markNeverConnectedNotified(ctx, device)
+	if err != nil || !ok {
+		return err
+	}
+	if action == model.MaintenanceActionSuppress {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionSuppressed, "never connected; maintenance window", "", 0)
+		return nil
+	}
+	outcome, err := this.sendNeverConnectedNotification(ctx, devicelog, device)
+	if err != nil {
+		this.auditNotification(ctx, devicelog, model.NotificationDecisionFailed, "never connected: "+err.Error(), "", 0)
+		//the device is marked before sending, so other instances do not send it concurrently; failed sends are retried after the lease
+		resetErr := this.resetNeverConnectedNotified(ctx, device)
+		if resetErr != nil {
+			log.Println("ERROR: resetNeverConnectedNotified()", device.DeviceId, resetErr)
+		}
+		return err
+	}
+	decision, reason := outcome.AuditDecision("never connected")
+	if decision != model.NotificationDecisionSent {
+		reason = "never connected; " + reason
+	}
+	this.auditNotification(ctx, devicelog, decision, reason, "", 0)
+	return nil
+}
+
+func (this *Controller) sendNeverConnectedNotification(ctx context.Context, devicelog model.DeviceLog, device NeverConnectedDevice) (DeliveryOutcome, error) {
+	if this.config.Debug {
+		log.Printf("DEBUG: send never connected notification for %#v\n", device)
+	}
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.Duration = device.Deadline.Sub(device.CreatedAt).Round(this.roundTime).String()
+	return this.deliverResourceNotification(ctx, this.deviceResource(devicelog), DeviceNeverConnectedTemplate, "device_offline", data, 0)
+}
+
+func (this *Controller) startNeverConnectedCheck(ctx context.Context) {
+	ticker := time.NewTicker(parseDurationWithDefault(this.config.NeverConnectedCheckInterval, 10*time.Second))
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := this.CheckNeverConnected(ctx)
+				if err != nil {
+					log.Println("ERROR: CheckNeverConnected()", err)
+				}
+			}
+		}
+	}()
+}
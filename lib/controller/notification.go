@@ -17,34 +17,66 @@
 package controller
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
-	"io"
 	"log"
-	"net/http"
+	"strconv"
 	"time"
 )
 
-func (this *Controller) handleNotifications(devicelog model.DeviceLog) {
+// handleNotifications decides about device notifications; every decision is recorded in the notification audit
+func (this *Controller) handleNotifications(ctx context.Context, devicelog model.DeviceLog) {
 	if devicelog.Connected {
-		err := this.removeDeviceOfflineNotificationInfos(devicelog.Id)
+		//recipients whose offline notification is still pending in a digest receive neither notification
+		pending := []string{}
+		if this.digestEnabled() {
+			var err error
+			pending, err = this.removeFromDigests(ctx, devicelog.Id)
+			if err != nil {
+				log.Println("ERROR: removeFromDigests()", err)
+			}
+		}
+		if this.notifyOnReconnect(devicelog) {
+			info, exists, err := this.getDeviceOfflineNotificationInfos(ctx, devicelog.Id)
+			if err != nil {
+				log.Println("ERROR: getDeviceOfflineNotificationInfos()", err)
+				return
+			}
+			switch {
+			case !exists:
+			case !info.Notified:
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionSkipped, "no offline notification sent", "", info.OfflineSince)
+			case devicelog.DeviceOwner == "":
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionMissingOwner, "device has no owner", "", info.OfflineSince)
+			default:
+				outcome, err := this.sendOnlineNotification(ctx, devicelog, info, pending)
+				if err != nil {
+					this.auditNotification(ctx, devicelog, model.NotificationDecisionFailed, err.Error(), "", info.OfflineSince)
+					log.Println("ERROR: unable to send online notification", err)
+					return
+				}
+				decision, reason := outcome.AuditDecision("device reconnected")
+				this.auditNotification(ctx, devicelog, decision, reason, "", info.OfflineSince)
+			}
+		}
+		err := this.removeDeviceOfflineNotificationInfos(ctx, devicelog.Id)
 		if err != nil {
 			log.Println("ERROR: removeDeviceOfflineNotificationInfos()", err)
 			return
 		}
 	} else {
-		info, exists, err := this.getDeviceOfflineNotificationInfos(devicelog.Id)
+		info, exists, err := this.getDeviceOfflineNotificationInfos(ctx, devicelog.Id)
 		if err != nil {
 			log.Println("ERROR: removeDeviceOfflineNotificationInfos()", err)
 			return
 		}
 		if !exists {
-			err = this.setDeviceOfflineNotificationInfos(DeviceOfflineNotificationInfo{
+			err = this.setDeviceOfflineNotificationInfos(ctx, DeviceOfflineNotificationInfo{
 				DeviceId:     devicelog.Id,
 				OfflineSince: devicelog.Time.Unix(),
 				Notified:     false,
@@ -53,29 +85,83 @@ func (this *Controller) handleNotifications(devicelog model.DeviceLog) {
 				log.Println("ERROR: setDeviceOfflineNotificationInfos()", err)
 				return
 			}
+			this.auditNotification(ctx, devicelog, model.NotificationDecisionPending, "device went offline", "", devicelog.Time.Unix())
 		} else {
-			if info.Notified == true || devicelog.MonitorConnectionState == "" || devicelog.DeviceOwner == "" {
+			if devicelog.MonitorConnectionState == "" {
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionSkipped, "monitor_connection_state not set", "", info.OfflineSince)
+				return
+			}
+			if devicelog.DeviceOwner == "" {
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionMissingOwner, "device has no owner", "", info.OfflineSince)
+				return
+			}
+			if _, ok := this.getDutyCycle(devicelog); ok {
+				//sleeping devices are monitored by their duty cycle
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionSkipped, "monitored by duty cycle", "", info.OfflineSince)
 				return
 			}
-			maxDur, err := time.ParseDuration(devicelog.MonitorConnectionState)
+			levels, err := ParseMonitorPolicy(devicelog.MonitorConnectionState)
 			if err != nil {
-				this.sendMonitorParseErrorNotification(devicelog, err)
-				log.Println("ERROR: ParseDuration()", err)
+				this.sendMonitorParseErrorNotification(ctx, devicelog, err)
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionParseError, err.Error(), "", info.OfflineSince)
+				log.Println("ERROR: ParseMonitorPolicy()", err)
 				return
 			}
+			if len(levels) == 0 {
+				//monitoring is turned off
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionSkipped, "monitoring turned off", "", info.OfflineSince)
+				return
+			}
+			if info.Notified && len(info.NotifiedLevels) == 0 {
+				//info created by a single threshold version
+				info.NotifiedLevels = []string{levels[0].Key()}
+			}
 			since := time.Since(time.Unix(info.OfflineSince, 0))
-			if since > maxDur {
-				err = this.sendOfflineNotification(devicelog, since)
-				if err != nil {
-					log.Println("ERROR: unable to send notification", err)
-					return
+			level, exceeded, ok := NextMonitorLevel(levels, since, info.NotifiedLevels)
+			if !ok {
+				if len(info.NotifiedLevels) > 0 {
+					this.auditNotification(ctx, devicelog, model.NotificationDecisionSkipped, "already notified", "", info.OfflineSince)
+				} else {
+					this.auditNotification(ctx, devicelog, model.NotificationDecisionPending, "offline duration below threshold", "", info.OfflineSince)
 				}
-				info.Notified = true
-				err = this.setDeviceOfflineNotificationInfos(info)
+				return
+			}
+			action := this.getMaintenanceAction(ctx, this.getDeviceMaintenanceTargets(ctx, devicelog))
+			if action == model.MaintenanceActionDefer {
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionDeferred, "maintenance window", level.Key(), info.OfflineSince)
+				return
+			}
+			reason := ""
+			if action == model.MaintenanceActionSuppress {
+				reason = "maintenance window"
+			} else if this.coveredByOutage(ctx, devicelog.Id) {
+				//devices of an outage are covered by the outage notification
+				reason = "outage"
+			}
+			if reason != "" {
+				info.NotifiedLevels = mergeNotifiedLevels(info.NotifiedLevels, exceeded)
+				err = this.setDeviceOfflineNotificationInfos(ctx, info)
 				if err != nil {
-					log.Println("ERROR: unable to update info with notified flag", err)
-					return
+					log.Println("ERROR: unable to update info with suppressed levels", err)
 				}
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionSuppressed, reason, level.Key(), info.OfflineSince)
+				return
+			}
+			outcome, err := this.sendOfflineNotification(ctx, devicelog, info, since, level)
+			if err != nil {
+				this.auditNotification(ctx, devicelog, model.NotificationDecisionFailed, err.Error(), level.Key(), info.OfflineSince)
+				log.Println("ERROR: unable to send notification", err)
+				return
+			}
+			//muted, dropped or deferred notifications are not repeated; the level counts as notified
+			decision, reason := outcome.AuditDecision("offline duration exceeded")
+			this.auditNotification(ctx, devicelog, decision, reason, level.Key(), info.OfflineSince)
+			info.Notified = true
+			info.NotifiedLevels = mergeNotifiedLevels(info.NotifiedLevels, exceeded)
+			err = this.setDeviceOfflineNotificationInfos(ctx, info)
+			if err != nil {
+				log.Println("ERROR: unable to update info with notified flag", err)
+				return
 			}
 		}
 	}
@@ -92,22 +178,27 @@ func (this *Controller) getDeviceOfflineNotificationInfoCollection() (session *m
 }
 
 type DeviceOfflineNotificationInfo struct {
-	DeviceId     string `json:"device_id" bson:"device_id"`
-	OfflineSince int64  `json:"offline_since" bson:"offline_since"`
-	Notified     bool   `json:"notified" bson:"notified"`
+	DeviceId       string   `json:"device_id" bson:"device_id"`
+	OfflineSince   int64    `json:"offline_since" bson:"offline_since"`
+	Notified       bool     `json:"notified" bson:"notified"`
+	NotifiedLevels []string `json:"notified_levels" bson:"notified_levels"`
 }
 
-func (this *Controller) removeDeviceOfflineNotificationInfos(deviceid string) error {
+func (this *Controller) removeDeviceOfflineNotificationInfos(ctx context.Context, deviceid string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeDeviceOfflineNotificationInfos", this.config.DeviceOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getDeviceOfflineNotificationInfoCollection()
 	defer session.Close()
-	_, err := collection.RemoveAll(bson.M{"device_id": deviceid})
+	_, err = collection.RemoveAll(bson.M{"device_id": deviceid})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (this *Controller) getDeviceOfflineNotificationInfos(deviceid string) (info DeviceOfflineNotificationInfo, found bool, err error) {
+func (this *Controller) getDeviceOfflineNotificationInfos(ctx context.Context, deviceid string) (info DeviceOfflineNotificationInfo, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getDeviceOfflineNotificationInfos", this.config.DeviceOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getDeviceOfflineNotificationInfoCollection()
 	defer session.Close()
 	list := []DeviceOfflineNotificationInfo{}
@@ -121,88 +212,251 @@ func (this *Controller) getDeviceOfflineNotificationInfos(deviceid string) (info
 	return list[0], true, nil
 }
 
-func (this *Controller) setDeviceOfflineNotificationInfos(info DeviceOfflineNotificationInfo) error {
+func (this *Controller) setDeviceOfflineNotificationInfos(ctx context.Context, info DeviceOfflineNotificationInfo) (err error) {
+	_, span := this.startMongoSpan(ctx, "setDeviceOfflineNotificationInfos", this.config.DeviceOfflineNotificationInfoCollection)
+	defer func() { tracing.End(span, err) }()
 	session, collection := this.getDeviceOfflineNotificationInfoCollection()
 	defer session.Close()
-	_, err := collection.Upsert(bson.M{"device_id": info.DeviceId}, info)
+	_, err = collection.Upsert(bson.M{"device_id": info.DeviceId}, info)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-type Notification struct {
-	UserId  string `json:"userId" bson:"userId"`
-	Title   string `json:"title" bson:"title"`
-	Message string `json:"message" bson:"message"`
-	Topic   string `json:"topic" bson:"topic"`
-}
-
-func (this *Controller) sendOfflineNotification(devicelog model.DeviceLog, since time.Duration) error {
+func (this *Controller) sendOfflineNotification(ctx context.Context, devicelog model.DeviceLog, info DeviceOfflineNotificationInfo, since time.Duration, level MonitorLevel) (DeliveryOutcome, error) {
 	if this.config.Debug {
 		log.Printf("DEBUG: send notification for %#v\n", devicelog)
 	}
-	b := new(bytes.Buffer)
-	err := json.NewEncoder(b).Encode(Notification{
-		UserId:  devicelog.DeviceOwner,
-		Title:   "Device Offline",
-		Message: fmt.Sprintf("device %v (%v) has been offline for %v", devicelog.DeviceName, devicelog.Id, since.Round(this.roundTime).String()),
-		Topic:   "device_offline",
-	})
-	if err != nil {
-		return err
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.Duration = since.Round(this.roundTime).String()
+	data.OfflineSince = time.Unix(info.OfflineSince, 0)
+	data.Severity = level.Severity
+	resource := this.deviceResource(devicelog)
+	if this.digestEnabled() {
+		errs := []error{}
+		outcome := DeliveryNoRecipients
+		for _, user := range this.getNotificationRecipients(ctx, resource) {
+			preferences := this.getUserPreferences(ctx, user)
+			if preferences.Muted(devicelog.Id) {
+				outcome = max(outcome, DeliveryMuted)
+				continue
+			}
+			if preferences.Delivery == model.DeliveryImmediate {
+				data.Recipient = user
+				userOutcome, err := this.deliverTemplateNotification(ctx, DeviceOfflineTemplate, resource.Locale, resource.recipientChannel(user), withDefault(level.Topic, "device_offline"), data, 0)
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					outcome = max(outcome, userOutcome)
+				}
+				continue
+			}
+			entry := DigestEntry{
+				DeviceId:     data.DeviceId,
+				Owner:        devicelog.DeviceOwner,
+				DeviceName:   data.DeviceName,
+				HubId:        data.HubId,
+				HubName:      data.HubName,
+				OfflineSince: info.OfflineSince,
+				Duration:     data.Duration,
+				Severity:     data.Severity,
+				Topic:        level.Topic,
+				Locale:       devicelog.Locale,
+				Channel:      resource.recipientChannel(user),
+			}
+			err := this.addToDigest(ctx, user, entry)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				outcome = max(outcome, DeliveryDigest)
+			}
+		}
+		return outcome, errors.Join(errs...)
 	}
-	endpoint := this.config.NotificationUrl + "/notifications"
-	req, err := http.NewRequest("POST", endpoint, b)
-	if err != nil {
-		return err
+	return this.deliverResourceNotification(ctx, resource, DeviceOfflineTemplate, withDefault(level.Topic, "device_offline"), data, 0)
+}
+
+func withDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	req.WithContext(ctx)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	return value
+}
+
+// sendOnlineNotification notifies the recipients of the device except exclude
+func (this *Controller) sendOnlineNotification(ctx context.Context, devicelog model.DeviceLog, info DeviceOfflineNotificationInfo, exclude []string) (DeliveryOutcome, error) {
+	if this.config.Debug {
+		log.Printf("DEBUG: send online notification for %#v\n", devicelog)
 	}
-	if resp.StatusCode >= 300 {
-		respMsg, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected response status from notifier %v %v", resp.Status, string(respMsg))
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.Duration = devicelog.Time.Sub(time.Unix(info.OfflineSince, 0)).Round(this.roundTime).String()
+	data.OfflineSince = time.Unix(info.OfflineSince, 0)
+	resource := this.deviceResource(devicelog)
+	resource.Exclude = exclude
+	return this.deliverResourceNotification(ctx, resource, DeviceOnlineTemplate, "device_offline", data, 0)
+}
+
+// notifyOnReconnect uses the notify_on_reconnect device attribute if set and the NotifyOnReconnect config otherwise
+func (this *Controller) notifyOnReconnect(devicelog model.DeviceLog) bool {
+	if devicelog.NotifyOnReconnect == "" {
+		return this.config.NotifyOnReconnect
 	}
-	return nil
+	enabled, err := strconv.ParseBool(devicelog.NotifyOnReconnect)
+	if err != nil {
+		log.Println("WARNING: invalid notify_on_reconnect value", devicelog.Id, devicelog.NotifyOnReconnect)
+		return this.config.NotifyOnReconnect
+	}
+	return enabled
 }
 
-func (this *Controller) sendMonitorParseErrorNotification(devicelog model.DeviceLog, err error) {
+func (this *Controller) sendMonitorParseErrorNotification(ctx context.Context, devicelog model.DeviceLog, err error) {
 	if this.config.Debug {
 		log.Printf("DEBUG: send parse error (%v) notification for %#v\n", err.Error(), devicelog)
 	}
-	b := new(bytes.Buffer)
-	err = json.NewEncoder(b).Encode(Notification{
-		UserId:  devicelog.DeviceOwner,
-		Title:   "Device monitor_connection_state Attribute Invalid",
-		Message: fmt.Sprintf("device %v (%v) has an invalid monitor_connection_state attribute (allowed time-shorthands are s,m,h); error = %v", devicelog.DeviceName, devicelog.Id, err.Error()),
-		Topic:   "device_offline",
-	})
+	data := this.getDeviceTemplateData(ctx, devicelog)
+	data.Error = err.Error()
+	err = this.sendTemplateNotification(ctx, DeviceMonitorErrorTemplate, devicelog.Locale, devicelog.NotificationChannel, "device_offline", data, 24*time.Hour)
 	if err != nil {
 		log.Println("ERROR: sendMonitorParseErrorNotification()", err)
-		return
 	}
-	endpoint := this.config.NotificationUrl + "/notifications?ignore_duplicates_within_seconds=86400"
-	req, err := http.NewRequest("POST", endpoint, b)
+	return
+}
+
+func (this *Controller) getDeviceTemplateData(ctx context.Context, devicelog model.DeviceLog) NotificationTemplateData {
+	data := NotificationTemplateData{
+		DeviceId:   devicelog.Id,
+		DeviceName: devicelog.DeviceName,
+		Owner:      devicelog.DeviceOwner,
+		Time:       time.Now(),
+		Metadata:   devicelog.Metadata,
+	}
+	hub, found, err := this.getHubMetadataByDevice(ctx, devicelog.Id)
 	if err != nil {
-		log.Println("ERROR: sendMonitorParseErrorNotification()", err)
-		return
+		log.Println("WARNING: unable to get hub of device for notification", devicelog.Id, err)
+	}
+	if found {
+		data.HubId = hub.HubId
+		data.HubName = hub.Name
+	}
+	return data
+}
+
+// getLocale uses the locale attribute of the device or hub if set, the locale preference of the user
+// and the locale from OwnerLocales otherwise
+func (this *Controller) getLocale(user string, attribute string, preferences model.NotificationPreferences) string {
+	if attribute != "" {
+		return attribute
+	}
+	if preferences.Locale != "" {
+		return preferences.Locale
+	}
+	if locale, ok := this.config.OwnerLocales[user]; ok {
+		return locale
+	}
+	return this.config.DefaultLocale
+}
+
+// getNotificationChannel uses the notification_channel attribute of the device or hub if set, the channel preference of the user
+// and the channel from OwnerNotificationChannels otherwise.
+// an empty result selects DefaultNotificationChannel.
+func (this *Controller) getNotificationChannel(user string, attribute string, preferences model.NotificationPreferences) string {
+	if attribute != "" {
+		return attribute
+	}
+	if preferences.Channel != "" {
+		return preferences.Channel
+	}
+	return this.config.OwnerNotificationChannels[user]
+}
+
+// DeliveryOutcome describes what happened to a notification which did not fail.
+// outcomes are ordered; the outcome of a notification to several recipients is the highest outcome of the recipients.
+type DeliveryOutcome int
+
+const (
+	DeliveryNoRecipients      DeliveryOutcome = iota //no recipient left, e.g. because all opted out
+	DeliveryMuted                                    //muted by the preferences of the recipient
+	DeliveryQuietHoursDropped                        //dropped during quiet hours of the recipient, because the outbox is disabled
+	DeliveryDigest                                   //collected in a digest
+	DeliveryDelayed                                  //delayed through the outbox until the quiet hours of the recipient end
+	DeliveryQueued                                   //queued in the outbox
+	DeliverySent
+)
+
+// AuditDecision returns the notification audit decision and reason of the outcome; reason is used for sent notifications
+func (this DeliveryOutcome) AuditDecision(reason string) (string, string) {
+	switch this {
+	case DeliveryNoRecipients:
+		return model.NotificationDecisionSuppressed, "no recipients"
+	case DeliveryMuted:
+		return model.NotificationDecisionSuppressed, "muted by user preferences"
+	case DeliveryQuietHoursDropped:
+		return model.NotificationDecisionSuppressed, "dropped during quiet hours"
+	case DeliveryDigest:
+		return model.NotificationDecisionDeferred, "collected in digest"
+	case DeliveryDelayed:
+		return model.NotificationDecisionDeferred, "delayed by quiet hours"
+	case DeliveryQueued:
+		return model.NotificationDecisionDeferred, "queued in outbox"
+	default:
+		return model.NotificationDecisionSent, reason
+	}
+}
+
+// sendTemplateNotification is deliverTemplateNotification for callers which do not distinguish delivery outcomes
+func (this *Controller) sendTemplateNotification(ctx context.Context, templateName string, localeAttribute string, channelAttribute string, topic string, data NotificationTemplateData, ignoreDuplicatesWithin time.Duration) error {
+	_, err := this.deliverTemplateNotification(ctx, templateName, localeAttribute, channelAttribute, topic, data, ignoreDuplicatesWithin)
+	return err
+}
+
+// deliverTemplateNotification renders and sends the template to data.Recipient (or data.Owner), respecting the preferences of the recipient.
+// localeAttribute and channelAttribute are the locale and notification_channel attributes of the device or hub, if they apply to the recipient.
+// notifications during quiet hours are delayed through the outbox; without outbox they are dropped with DeliveryQuietHoursDropped.
+func (this *Controller) deliverTemplateNotification(ctx context.Context, templateName string, localeAttribute string, channelAttribute string, topic string, data NotificationTemplateData, ignoreDuplicatesWithin time.Duration) (DeliveryOutcome, error) {
+	user := withDefault(data.Recipient, data.Owner)
+	preferences := this.getUserPreferences(ctx, user)
+	if preferences.Muted(withDefault(data.DeviceId, data.HubId)) {
+		if this.config.Debug {
+			log.Println("DEBUG: notification muted by user preferences", user, withDefault(data.DeviceId, data.HubId))
+		}
+		return DeliveryMuted, nil
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	req.WithContext(ctx)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	title, message, err := this.templates.Render(templateName, this.getLocale(user, localeAttribute, preferences), data)
 	if err != nil {
-		log.Println("ERROR: sendMonitorParseErrorNotification()", err)
-		return
+		return DeliveryNoRecipients, err
 	}
-	if resp.StatusCode >= 300 {
-		respMsg, _ := io.ReadAll(resp.Body)
-		log.Printf("ERROR: unexpected response status from notifier %v %v\n", resp.Status, string(respMsg))
+	notification := notifier.Notification{
+		UserId:                 user,
+		Title:                  title,
+		Message:                message,
+		Topic:                  topic,
+		IgnoreDuplicatesWithin: ignoreDuplicatesWithin,
 	}
-	return
+	channel := this.getNotificationChannel(user, channelAttribute, preferences)
+	notBefore := time.Now()
+	outcome := DeliveryQueued
+	if preferences.QuietHours != nil {
+		end, quiet := preferences.QuietHours.ActiveUntil(notBefore)
+		if quiet && !this.outboxEnabled() {
+			log.Println("WARNING: drop notification during quiet hours; quiet hours need the notification outbox", user, topic)
+			return DeliveryQuietHoursDropped, nil
+		}
+		if quiet {
+			notBefore = end
+			outcome = DeliveryDelayed
+		}
+	}
+	if this.outboxEnabled() {
+		err = this.enqueueNotification(ctx, channel, notification, notBefore)
+		if err != nil {
+			return DeliveryNoRecipients, err
+		}
+		return outcome, nil
+	}
+	err = this.notifier.Notify(ctx, channel, notification)
+	if err != nil {
+		return DeliveryNoRecipients, err
+	}
+	return DeliverySent, nil
 }
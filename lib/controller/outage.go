@@ -0,0 +1,347 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+const (
+	OutageScopeHub   = "hub"
+	OutageScopeOwner = "owner"
+)
+
+// Outage is a correlated drop of devices of a hub or an owner.
+// records with Detected == false are candidates collecting drops until WindowEnd.
+type Outage struct {
+	Id              bson.ObjectId `json:"id" bson:"_id"`
+	Scope           string        `json:"scope" bson:"scope"`
+	TargetId        string        `json:"target_id" bson:"target_id"`
+	Owner           string        `json:"owner" bson:"owner"`
+	Start           time.Time     `json:"start" bson:"start"`
+	End             *time.Time    `json:"end" bson:"end"`
+	WindowEnd       time.Time     `json:"window_end" bson:"window_end"`
+	Detected        bool          `json:"detected" bson:"detected"`
+	Notified        bool          `json:"notified" bson:"notified"`
+	AffectedDevices []string      `json:"affected_devices" bson:"affected_devices"`
+	AffectedHubs    []string      `json:"affected_hubs" bson:"affected_hubs"`
+	Offline         []string      `json:"offline" bson:"offline"` //affected devices and hubs which did not reconnect
+}
+
+// OutageThresholdReached checks if the drops of a candidate are an outage:
+// at least minDevices devices or more than percent of total devices (at least 2); dropped hubs do not count.
+// minDevices and percent <= 0 disable their rule; a total <= 0 (unknown) disables the percent rule.
+func OutageThresholdReached(candidate Outage, total int, minDevices int64, percent float64) bool {
+	devices := len(candidate.AffectedDevices)
+	if minDevices > 0 && int64(devices) >= minDevices {
+		return true
+	}
+	return percent > 0 && devices >= 2 && total > 0 && float64(devices)*100/float64(total) > percent
+}
+
+type outageGroup struct {
+	scope    string
+	targetId string
+	owner    string
+	total    int //known devices of the group
+}
+
+func (this *Controller) outageEnabled() bool {
+	return this.outageWindow > 0
+}
+
+func (this *Controller) getOutageCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.OutageCollection)
+	err := collection.EnsureIndexKey("scope", "target_id", "end")
+	if err != nil {
+		log.Fatal("error on getOutageCollection scope index: ", err)
+	}
+	err = collection.EnsureIndexKey("offline")
+	if err != nil {
+		log.Fatal("error on getOutageCollection offline index: ", err)
+	}
+	err = collection.EnsureIndexKey("affected_devices")
+	if err != nil {
+		log.Fatal("error on getOutageCollection affected_devices index: ", err)
+	}
+	err = collection.EnsureIndexKey("affected_hubs")
+	if err != nil {
+		log.Fatal("error on getOutageCollection affected_hubs index: ", err)
+	}
+	return
+}
+
+// handleDeviceOutage registers state changes of devices for the outage detection; sleeping devices are ignored
+func (this *Controller) handleDeviceOutage(ctx context.Context, devicelog model.DeviceLog, sleeping bool) {
+	if !this.outageEnabled() || sleeping {
+		return
+	}
+	if devicelog.Connected {
+		this.handleOutageReconnect(ctx, devicelog.Id)
+		return
+	}
+	deviceId, owner := devicelog.Id, devicelog.DeviceOwner
+	groups := []outageGroup{}
+	hub, found, err := this.getHubMetadataByDevice(ctx, deviceId)
+	if err != nil {
+		log.Println("WARNING: unable to get hub of device for outage detection", deviceId, err)
+	}
+	if found {
+		groups = append(groups, outageGroup{scope: OutageScopeHub, targetId: hub.HubId, owner: hub.OwnerId, total: len(hub.DeviceIds)})
+	}
+	if owner != "" {
+		total, err := this.countOwnerDevices(ctx, owner)
+		if err != nil {
+			log.Println("WARNING: unable to count devices of owner for outage detection", owner, err)
+		}
+		groups = append(groups, outageGroup{scope: OutageScopeOwner, targetId: owner, owner: owner, total: total})
+	}
+	for _, group := range groups {
+		err = this.addOutageDrop(ctx, group, "affected_devices", deviceId)
+		if err != nil {
+			log.Println("ERROR: addOutageDrop()", group.scope, group.targetId, err)
+		}
+	}
+}
+
+// handleHubOutage registers state changes of hubs for the outage detection
+func (this *Controller) handleHubOutage(ctx context.Context, hublog model.HubLog) {
+	if !this.outageEnabled() {
+		return
+	}
+	if hublog.Connected {
+		this.handleOutageReconnect(ctx, hublog.Id)
+		return
+	}
+	hub, err := this.getHubOfflineInfo(ctx, hublog)
+	if err != nil {
+		log.Println("WARNING: unable to get hub metadata for outage detection", hublog.Id, err)
+	}
+	err = this.addOutageDrop(ctx, outageGroup{scope: OutageScopeHub, targetId: hub.HubId, owner: hub.HubOwner, total: len(hub.DeviceIds)}, "affected_hubs", hub.HubId)
+	if err != nil {
+		log.Println("ERROR: addOutageDrop()", OutageScopeHub, hub.HubId, err)
+	}
+}
+
+// addOutageDrop adds the device or hub to the active outage of the group or to its candidate.
+// candidates reaching the threshold are detected as outage.
+func (this *Controller) addOutageDrop(ctx context.Context, group outageGroup, field string, id string) (err error) {
+	_, span := this.startMongoSpan(ctx, "addOutageDrop", this.config.OutageCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOutageCollection()
+	defer session.Close()
+	now := time.Now()
+	err = collection.Update(bson.M{"scope": group.scope, "target_id": group.targetId, "end": nil, "detected": true}, bson.M{"$addToSet": bson.M{field: id, "offline": id}})
+	if !errors.Is(err, mgo.ErrNotFound) {
+		return err
+	}
+	_, err = collection.RemoveAll(bson.M{"scope": group.scope, "target_id": group.targetId, "end": nil, "detected": false, "window_end": bson.M{"$lt": now}})
+	if err != nil {
+		return err
+	}
+	candidate := Outage{}
+	_, err = collection.Find(bson.M{"scope": group.scope, "target_id": group.targetId, "end": nil, "detected": false}).Apply(mgo.Change{
+		Update: bson.M{
+			"$setOnInsert": bson.M{
+				"owner":      group.owner,
+				"start":      now,
+				"window_end": now.Add(this.outageWindow),
+				"notified":   false,
+			},
+			"$addToSet": bson.M{field: id, "offline": id},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &candidate)
+	if err != nil {
+		return err
+	}
+	if !OutageThresholdReached(candidate, group.total, this.config.OutageMinDevices, this.config.OutagePercent) {
+		return nil
+	}
+	if group.scope == OutageScopeOwner && len(candidate.AffectedDevices) > 0 {
+		//the devices of a hub outage are already notified by the hub outage; the candidate waits for further devices
+		covered, err := collection.Find(bson.M{"scope": OutageScopeHub, "end": nil, "detected": true, "affected_devices": bson.M{"$all": candidate.AffectedDevices}}).Limit(1).Count()
+		if err != nil {
+			return err
+		}
+		if covered > 0 {
+			return nil
+		}
+	}
+	if this.config.Debug {
+		log.Printf("DEBUG: detected outage %v %v with %v devices\n", group.scope, group.targetId, len(candidate.AffectedDevices))
+	}
+	err = collection.Update(bson.M{"_id": candidate.Id, "detected": false}, bson.M{"$set": bson.M{"detected": true}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// handleOutageReconnect removes the device or hub from the offline ids; outages without offline ids are ended
+func (this *Controller) handleOutageReconnect(ctx context.Context, id string) {
+	err := this.removeOutageOffline(ctx, id)
+	if err != nil {
+		log.Println("ERROR: removeOutageOffline()", err)
+	}
+}
+
+func (this *Controller) removeOutageOffline(ctx context.Context, id string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeOutageOffline", this.config.OutageCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOutageCollection()
+	defer session.Close()
+	_, err = collection.UpdateAll(bson.M{"end": nil, "detected": false, "offline": id}, bson.M{"$pull": bson.M{"offline": id, "affected_devices": id, "affected_hubs": id}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateAll(bson.M{"end": nil, "detected": true, "offline": id}, bson.M{"$pull": bson.M{"offline": id}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateAll(bson.M{"end": nil, "detected": true, "offline": bson.M{"$size": 0}}, bson.M{"$set": bson.M{"end": time.Now()}})
+	return err
+}
+
+// countOwnerDevices counts the device states of owner.
+// states stored before the owner was recorded have no owner field until the next device log or device command of the device backfills it;
+// while such states exist, they may belong to owner, so the count is unknown and 0 (percent rule skipped) is returned.
+func (this *Controller) countOwnerDevices(ctx context.Context, owner string) (count int, err error) {
+	if this.config.OutagePercent <= 0 {
+		return 0, nil
+	}
+	_, span := this.startMongoSpan(ctx, "countOwnerDevices", this.config.DeviceStateCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getDeviceStateCollection()
+	defer session.Close()
+	incomplete, err := collection.Find(bson.M{"owner": bson.M{"$exists": false}}).Count()
+	if err != nil {
+		return 0, err
+	}
+	if incomplete > 0 {
+		log.Println("WARNING: skip OutagePercent rule for owner", owner, "because", incomplete, "device states have no owner yet; owners are backfilled by device logs and device commands")
+		return 0, nil
+	}
+	return collection.Find(bson.M{"owner": owner}).Count()
+}
+
+func (this *Controller) getActiveOutage(ctx context.Context, id string) (outage Outage, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getActiveOutage", this.config.OutageCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOutageCollection()
+	defer session.Close()
+	list := []Outage{}
+	err = collection.Find(bson.M{"end": nil, "detected": true, "$or": []bson.M{{"affected_devices": id}, {"affected_hubs": id}}}).Sort("start").Limit(1).All(&list)
+	if err != nil || len(list) == 0 {
+		return outage, false, err
+	}
+	return list[0], true, nil
+}
+
+// claimOutageNotification returns true for the first caller of a not notified outage
+func (this *Controller) claimOutageNotification(ctx context.Context, outage Outage) (ok bool, err error) {
+	_, span := this.startMongoSpan(ctx, "claimOutageNotification", this.config.OutageCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOutageCollection()
+	defer session.Close()
+	err = collection.Update(bson.M{"_id": outage.Id, "notified": false}, bson.M{"$set": bson.M{"notified": true}})
+	if errors.Is(err, mgo.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// coveredByOutage returns true if the device or hub is part of an active outage.
+// the first device or hub of an outage, which would be notified individually, triggers the outage notification instead.
+func (this *Controller) coveredByOutage(ctx context.Context, id string) bool {
+	if !this.outageEnabled() {
+		return false
+	}
+	outage, found, err := this.getActiveOutage(ctx, id)
+	if err != nil {
+		log.Println("ERROR: getActiveOutage()", err)
+		return false
+	}
+	if !found {
+		return false
+	}
+	if outage.Notified {
+		return true
+	}
+	ok, err := this.claimOutageNotification(ctx, outage)
+	if err != nil {
+		log.Println("ERROR: claimOutageNotification()", err)
+		return true
+	}
+	if ok {
+		err = this.sendOutageNotification(ctx, outage)
+		if err != nil {
+			log.Println("ERROR: sendOutageNotification()", err)
+		}
+	}
+	return true
+}
+
+func (this *Controller) sendOutageNotification(ctx context.Context, outage Outage) error {
+	if this.config.Debug {
+		log.Printf("DEBUG: send outage notification for %#v\n", outage)
+	}
+	data := NotificationTemplateData{
+		Owner:              outage.Owner,
+		Time:               time.Now(),
+		OfflineSince:       outage.Start,
+		Duration:           time.Since(outage.Start).Round(this.roundTime).String(),
+		Devices:            len(outage.AffectedDevices),
+		UnreachableDevices: len(outage.AffectedDevices),
+	}
+	resource := NotificationResource{Owner: outage.Owner}
+	if outage.Scope == OutageScopeHub {
+		hub := HubOfflineInfo{HubId: outage.TargetId, HubOwner: outage.Owner}
+		metadata, found, err := this.getHubMetadata(ctx, outage.TargetId)
+		if err != nil {
+			log.Println("WARNING: unable to get hub metadata for outage notification", outage.TargetId, err)
+		}
+		if found {
+			hub.HubName = metadata.Name
+			hub.Locale = metadata.Locale
+			hub.NotificationChannel = metadata.NotificationChannel
+		}
+		data.HubId = hub.HubId
+		data.HubName = hub.HubName
+		resource = this.hubResource(hub)
+	}
+	offline := map[string]bool{}
+	for _, id := range outage.Offline {
+		offline[id] = true
+	}
+	data.UnreachableDevices = 0
+	for _, id := range outage.AffectedDevices {
+		if offline[id] {
+			data.UnreachableDevices++
+		}
+	}
+	return this.sendResourceNotification(ctx, resource, OutageTemplate, "outage", data, 0)
+}
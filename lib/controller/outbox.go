@@ -0,0 +1,228 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"strings"
+	"time"
+)
+
+// OutboxEntry is a pending notification for a single channel target
+type OutboxEntry struct {
+	Id                     bson.ObjectId         `json:"id" bson:"_id"`
+	Target                 string                `json:"target" bson:"target"`
+	Notification           notifier.Notification `json:"notification" bson:"notification"`
+	IgnoreDuplicatesWithin int64                 `json:"ignore_duplicates_within" bson:"ignore_duplicates_within"` //seconds
+	TraceContext           map[string]string     `json:"trace_context" bson:"trace_context"`
+	CreatedAt              time.Time             `json:"created_at" bson:"created_at"`
+	NextAttempt            time.Time             `json:"next_attempt" bson:"next_attempt"`
+	LockedUntil            time.Time             `json:"locked_until" bson:"locked_until"`
+	Attempts               int                   `json:"attempts" bson:"attempts"`
+	LastError              string                `json:"last_error" bson:"last_error"`
+	MessageKey             string                `json:"message_key,omitempty" bson:"message_key,omitempty"`
+	CompletedAt            *time.Time            `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+// outboxLease is the time a claimed entry is hidden from other dispatchers
+const outboxLease = time.Minute
+
+const outboxBatchSize = 100
+
+func (this *Controller) outboxEnabled() bool {
+	return this.config.NotificationOutboxCollection != "" && this.config.NotificationOutboxCollection != "-"
+}
+
+func (this *Controller) getOutboxCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NotificationOutboxCollection)
+	err := collection.EnsureIndexKey("next_attempt", "locked_until")
+	if err != nil {
+		log.Fatal("error on getOutboxCollection next_attempt index: ", err)
+	}
+	err = collection.EnsureIndex(mgo.Index{
+		Key:         []string{"completed_at"},
+		ExpireAfter: this.processedEventTtl,
+	})
+	if err != nil {
+		log.Fatal("error on getOutboxCollection completed_at index: ", err)
+	}
+	return
+}
+
+// OutboxEntryId derives the id of entries enqueued while processing a consumed message from the message key,
+// so that a repeated processing of the message creates the same ids and does not enqueue the notification again.
+// the message is not part of the id, because it may contain durations which change on a repeated processing.
+// outside of message processing (empty key) a new id is used.
+func OutboxEntryId(messageKey string, target string, notification notifier.Notification) bson.ObjectId {
+	if messageKey == "" {
+		return bson.NewObjectId()
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{messageKey, target, notification.UserId, notification.Topic, notification.Title}, "\x00")))
+	return bson.ObjectId(hash[:12])
+}
+
+// enqueueNotification stores the notification for every target of channel in the outbox.
+// the first attempt is made at notBefore; NotificationOutboxMaxAge is measured from notBefore.
+// entries already enqueued by an earlier processing of the same consumed message are skipped.
+func (this *Controller) enqueueNotification(ctx context.Context, channel string, notification notifier.Notification, notBefore time.Time) (err error) {
+	ctx, span := this.startMongoSpan(ctx, "enqueueNotification", this.config.NotificationOutboxCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOutboxCollection()
+	defer session.Close()
+	messageKey := model.MessageKeyFromContext(ctx)
+	inserted := false
+	for _, target := range this.notifier.Targets(channel) {
+		err = collection.Insert(OutboxEntry{
+			Id:                     OutboxEntryId(messageKey, target, notification),
+			Target:                 target,
+			Notification:           notification,
+			IgnoreDuplicatesWithin: int64(notification.IgnoreDuplicatesWithin.Seconds()),
+			TraceContext:           tracing.Inject(ctx),
+			CreatedAt:              notBefore,
+			NextAttempt:            notBefore,
+			LockedUntil:            notBefore,
+			MessageKey:             messageKey,
+		})
+		if mgo.IsDup(err) {
+			if this.config.Debug {
+				log.Println("DEBUG: notification already enqueued for message", messageKey, target)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		inserted = true
+	}
+	if !inserted {
+		return nil
+	}
+	select {
+	case this.outboxTrigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (this *Controller) claimOutboxEntry(now time.Time) (entry OutboxEntry, found bool, err error) {
+	session, collection := this.getOutboxCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{
+		"next_attempt": bson.M{"$lte": now},
+		"locked_until": bson.M{"$lte": now},
+		"completed_at": bson.M{"$exists": false},
+	}).Sort("next_attempt", "_id").Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"locked_until": now.Add(outboxLease)}},
+		ReturnNew: true,
+	}, &entry)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return entry, false, nil
+	}
+	if err != nil {
+		return entry, false, err
+	}
+	return entry, true, nil
+}
+
+// DispatchOutbox delivers due outbox entries; failed deliveries are retried with exponential backoff until NotificationOutboxMaxAge
+func (this *Controller) DispatchOutbox(ctx context.Context) error {
+	for i := 0; i < outboxBatchSize && ctx.Err() == nil; i++ {
+		entry, found, err := this.claimOutboxEntry(time.Now())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+		err = this.dispatchOutboxEntry(ctx, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *Controller) dispatchOutboxEntry(ctx context.Context, entry OutboxEntry) error {
+	session, collection := this.getOutboxCollection()
+	defer session.Close()
+	notification := entry.Notification
+	notification.IgnoreDuplicatesWithin = time.Duration(entry.IgnoreDuplicatesWithin) * time.Second
+	notification.Id = entry.Id.Hex()
+	deliveryErr := this.notifier.Notify(tracing.Extract(ctx, entry.TraceContext), entry.Target, notification)
+	if deliveryErr == nil {
+		return this.completeOutboxEntry(collection, entry)
+	}
+	entry.Attempts = entry.Attempts + 1
+	if time.Since(entry.CreatedAt) > this.outboxMaxAge {
+		log.Println("ERROR: drop undeliverable notification after", entry.Attempts, "attempts:", entry.Target, entry.Notification.UserId, entry.Notification.Title, deliveryErr)
+		return this.completeOutboxEntry(collection, entry)
+	}
+	log.Println("WARNING: notification delivery failed; retry later:", entry.Target, entry.Attempts, deliveryErr)
+	next := time.Now().Add(this.outboxBackoff(entry.Attempts))
+	return collection.UpdateId(entry.Id, bson.M{"$set": bson.M{
+		"attempts":     entry.Attempts,
+		"last_error":   deliveryErr.Error(),
+		"next_attempt": next,
+		"locked_until": next,
+	}})
+}
+
+// completeOutboxEntry removes the entry; entries of consumed messages are kept until ProcessedEventTtl
+// to detect a repeated processing of the message
+func (this *Controller) completeOutboxEntry(collection *mgo.Collection, entry OutboxEntry) error {
+	if entry.MessageKey == "" {
+		return collection.RemoveId(entry.Id)
+	}
+	return collection.UpdateId(entry.Id, bson.M{"$set": bson.M{"completed_at": time.Now()}})
+}
+
+// outboxBackoff doubles NotificationOutboxRetryInterval with every attempt up to NotificationOutboxMaxBackoff
+func (this *Controller) outboxBackoff(attempts int) time.Duration {
+	result := this.outboxRetryInterval
+	for i := 1; i < attempts && result < this.outboxMaxBackoff; i++ {
+		result = result * 2
+	}
+	return min(result, this.outboxMaxBackoff)
+}
+
+func (this *Controller) startOutboxDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(min(this.outboxRetryInterval, time.Second))
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-this.outboxTrigger:
+			}
+			err := this.DispatchOutbox(ctx)
+			if err != nil {
+				log.Println("ERROR: DispatchOutbox()", err)
+			}
+		}
+	}()
+}
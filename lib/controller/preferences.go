@@ -0,0 +1,111 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+)
+
+func (this *Controller) preferencesEnabled() bool {
+	return this.config.NotificationPreferenceCollection != "" && this.config.NotificationPreferenceCollection != "-"
+}
+
+func (this *Controller) getPreferenceCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NotificationPreferenceCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"user_id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getPreferenceCollection user_id index: ", err)
+	}
+	return
+}
+
+// countQuietHoursPreferences counts the users with quiet hours
+func (this *Controller) countQuietHoursPreferences(ctx context.Context) (count int, err error) {
+	_, span := this.startMongoSpan(ctx, "countQuietHoursPreferences", this.config.NotificationPreferenceCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getPreferenceCollection()
+	defer session.Close()
+	return collection.Find(bson.M{"quiet_hours": bson.M{"$ne": nil}}).Count()
+}
+
+// checkQuietHoursPreferences warns about stored quiet hours, which drop notifications while the outbox is disabled
+func (this *Controller) checkQuietHoursPreferences(ctx context.Context) {
+	if !this.preferencesEnabled() || this.outboxEnabled() {
+		return
+	}
+	count, err := this.countQuietHoursPreferences(ctx)
+	if err != nil {
+		log.Println("ERROR: countQuietHoursPreferences()", err)
+		return
+	}
+	if count > 0 {
+		log.Println("WARNING:", count, "users have quiet hours, which need the notification outbox; their notifications during quiet hours are dropped")
+	}
+}
+
+func (this *Controller) GetNotificationPreferences(ctx context.Context, userId string) (result model.NotificationPreferences, found bool, err error) {
+	_, span := this.startMongoSpan(ctx, "GetNotificationPreferences", this.config.NotificationPreferenceCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getPreferenceCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"user_id": userId}).One(&result)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return model.NotificationPreferences{UserId: userId}, false, nil
+	}
+	if err != nil {
+		return result, false, err
+	}
+	return result, true, nil
+}
+
+func (this *Controller) SetNotificationPreferences(ctx context.Context, preferences model.NotificationPreferences) (err error) {
+	_, span := this.startMongoSpan(ctx, "SetNotificationPreferences", this.config.NotificationPreferenceCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getPreferenceCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"user_id": preferences.UserId}, preferences)
+	return err
+}
+
+func (this *Controller) DeleteNotificationPreferences(ctx context.Context, userId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "DeleteNotificationPreferences", this.config.NotificationPreferenceCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getPreferenceCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"user_id": userId})
+	return err
+}
+
+// getUserPreferences returns the preferences of user or empty preferences if none are stored or they can not be loaded
+func (this *Controller) getUserPreferences(ctx context.Context, user string) model.NotificationPreferences {
+	if !this.preferencesEnabled() || user == "" {
+		return model.NotificationPreferences{UserId: user}
+	}
+	preferences, _, err := this.GetNotificationPreferences(ctx, user)
+	if err != nil {
+		log.Println("ERROR: unable to load notification preferences; use defaults", user, err)
+		return model.NotificationPreferences{UserId: user}
+	}
+	return preferences
+}
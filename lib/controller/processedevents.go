@@ -0,0 +1,68 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"time"
+)
+
+type ProcessedEvent struct {
+	Key       string    `json:"key" bson:"_id"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (this *Controller) getProcessedEventCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.ProcessedEventCollection)
+	err := collection.EnsureIndex(mgo.Index{
+		Key:         []string{"created_at"},
+		ExpireAfter: this.processedEventTtl,
+	})
+	if err != nil {
+		log.Fatal("error on getProcessedEventCollection created_at index: ", err)
+	}
+	return
+}
+
+// IsProcessed implements listener.Deduplicator
+func (this *Controller) IsProcessed(ctx context.Context, key string) (processed bool, err error) {
+	_, span := this.startMongoSpan(ctx, "IsProcessed", this.config.ProcessedEventCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getProcessedEventCollection()
+	defer session.Close()
+	count, err := collection.FindId(key).Limit(1).Count()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MarkProcessed implements listener.Deduplicator
+// the mark is removed by mongodb after config.ProcessedEventTtl
+func (this *Controller) MarkProcessed(ctx context.Context, key string) (err error) {
+	_, span := this.startMongoSpan(ctx, "MarkProcessed", this.config.ProcessedEventCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getProcessedEventCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"_id": key}, ProcessedEvent{Key: key, CreatedAt: time.Now()})
+	return err
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	permv2 "github.com/SENERGY-Platform/permissions-v2/pkg/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"slices"
+	"time"
+)
+
+// NotificationResource is the device or hub a notification is about
+type NotificationResource struct {
+	Topic   string //permissions-v2 topic of the resource
+	Id      string
+	Owner   string
+	Locale  string   //locale attribute of the resource
+	Channel string   //notification_channel attribute of the resource; used for the owner only
+	Exclude []string //users which are not notified
+}
+
+func (this *Controller) deviceResource(devicelog model.DeviceLog) NotificationResource {
+	return NotificationResource{
+		Topic:   this.config.DeviceTopic,
+		Id:      devicelog.Id,
+		Owner:   devicelog.DeviceOwner,
+		Locale:  devicelog.Locale,
+		Channel: devicelog.NotificationChannel,
+	}
+}
+
+func (this *Controller) hubResource(hub HubOfflineInfo) NotificationResource {
+	return NotificationResource{
+		Topic:   this.config.HubTopic,
+		Id:      hub.HubId,
+		Owner:   hub.HubOwner,
+		Locale:  hub.Locale,
+		Channel: hub.NotificationChannel,
+	}
+}
+
+// ValidateNotificationPermission checks a NotificationPermission config value (e.g. "r" or "rx")
+func ValidateNotificationPermission(required string) error {
+	for _, p := range required {
+		switch permv2.Permission(p) {
+		case permv2.Read, permv2.Write, permv2.Execute, permv2.Administrate:
+		default:
+			return fmt.Errorf("unknown permission '%v'", string(p))
+		}
+	}
+	return nil
+}
+
+func hasPermissions(permissions permv2.PermissionsMap, required string) bool {
+	for _, p := range required {
+		switch permv2.Permission(p) {
+		case permv2.Read:
+			if !permissions.Read {
+				return false
+			}
+		case permv2.Write:
+			if !permissions.Write {
+				return false
+			}
+		case permv2.Execute:
+			if !permissions.Execute {
+				return false
+			}
+		case permv2.Administrate:
+			if !permissions.Administrate {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NotificationRecipients returns the owner followed by the sorted users with the required permissions.
+// group and role permissions are not resolved to users.
+func NotificationRecipients(owner string, permissions permv2.ResourcePermissions, required string) (result []string) {
+	if owner != "" {
+		result = append(result, owner)
+	}
+	users := []string{}
+	for user, userPermissions := range permissions.UserPermissions {
+		if user != owner && hasPermissions(userPermissions, required) {
+			users = append(users, user)
+		}
+	}
+	slices.Sort(users)
+	return append(result, users...)
+}
+
+func (this *Controller) permissionsEnabled() bool {
+	return this.permissions != nil
+}
+
+// getNotificationRecipients returns the owner and the users the resource is shared with, without excluded users and users who opted out.
+// if the permissions can not be loaded or the resource has no topic (e.g. owner outages), the owner is notified alone.
+func (this *Controller) getNotificationRecipients(ctx context.Context, resource NotificationResource) (result []string) {
+	result = NotificationRecipients(resource.Owner, permv2.ResourcePermissions{}, "")
+	if this.permissionsEnabled() && resource.Topic != "" {
+		permissions, err := this.getResourcePermissions(ctx, resource)
+		if err != nil {
+			log.Println("ERROR: unable to load permissions; notify owner only", resource.Topic, resource.Id, err)
+		} else {
+			result = NotificationRecipients(resource.Owner, permissions, this.config.NotificationPermission)
+		}
+	}
+	result = slices.DeleteFunc(result, func(user string) bool {
+		return slices.Contains(resource.Exclude, user)
+	})
+	if !this.optOutEnabled() || len(result) == 0 {
+		return result
+	}
+	optedOut, err := this.getOptedOutUsers(ctx, resource.Id, result)
+	if err != nil {
+		log.Println("ERROR: unable to load notification opt-outs", resource.Id, err)
+		return result
+	}
+	return slices.DeleteFunc(result, func(user string) bool {
+		return optedOut[user]
+	})
+}
+
+func (this *Controller) getResourcePermissions(ctx context.Context, resource NotificationResource) (result permv2.ResourcePermissions, err error) {
+	_, span := tracer.Start(ctx, "permissions-v2 GetResource", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.String("resource.topic", resource.Topic), attribute.String("resource.id", resource.Id)))
+	defer func() { tracing.End(span, err) }()
+	token, err := this.tokens.Authorization(permv2.InternalAdminToken)
+	if err != nil {
+		return result, err
+	}
+	temp, err, _ := this.permissions.GetResource(token, resource.Topic, resource.Id)
+	if err != nil {
+		return result, err
+	}
+	return temp.ResourcePermissions, nil
+}
+
+// sendResourceNotification renders and sends the template for every recipient of the resource
+func (this *Controller) sendResourceNotification(ctx context.Context, resource NotificationResource, templateName string, topic string, data NotificationTemplateData, ignoreDuplicatesWithin time.Duration) error {
+	_, err := this.deliverResourceNotification(ctx, resource, templateName, topic, data, ignoreDuplicatesWithin)
+	return err
+}
+
+// deliverResourceNotification is sendResourceNotification returning the highest delivery outcome of the recipients
+func (this *Controller) deliverResourceNotification(ctx context.Context, resource NotificationResource, templateName string, topic string, data NotificationTemplateData, ignoreDuplicatesWithin time.Duration) (DeliveryOutcome, error) {
+	errs := []error{}
+	outcome := DeliveryNoRecipients
+	for _, user := range this.getNotificationRecipients(ctx, resource) {
+		data.Recipient = user
+		userOutcome, err := this.deliverTemplateNotification(ctx, templateName, resource.Locale, resource.recipientChannel(user), topic, data, ignoreDuplicatesWithin)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		outcome = max(outcome, userOutcome)
+	}
+	return outcome, errors.Join(errs...)
+}
+
+// recipientChannel returns the notification_channel attribute of the resource for its owner only,
+// because the attribute may contain addresses of the owner
+func (this NotificationResource) recipientChannel(user string) string {
+	if user == this.Owner {
+		return this.Channel
+	}
+	return ""
+}
+
+func (this *Controller) optOutEnabled() bool {
+	return this.config.NotificationOptOutCollection != "" && this.config.NotificationOptOutCollection != "-"
+}
+
+func (this *Controller) getOptOutCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NotificationOptOutCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"user_id", "resource_id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getOptOutCollection user_id index: ", err)
+	}
+	err = collection.EnsureIndexKey("resource_id")
+	if err != nil {
+		log.Fatal("error on getOptOutCollection resource_id index: ", err)
+	}
+	return
+}
+
+func (this *Controller) getOptedOutUsers(ctx context.Context, resourceId string, users []string) (result map[string]bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getOptedOutUsers", this.config.NotificationOptOutCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOptOutCollection()
+	defer session.Close()
+	list := []model.NotificationOptOut{}
+	err = collection.Find(bson.M{"user_id": bson.M{"$in": users}, "resource_id": bson.M{"$in": []string{resourceId, model.OptOutAllResources}}}).All(&list)
+	if err != nil {
+		return nil, err
+	}
+	result = map[string]bool{}
+	for _, optOut := range list {
+		result[optOut.UserId] = true
+	}
+	return result, nil
+}
+
+func (this *Controller) ListNotificationOptOuts(ctx context.Context, userId string) (result []model.NotificationOptOut, err error) {
+	_, span := this.startMongoSpan(ctx, "ListNotificationOptOuts", this.config.NotificationOptOutCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOptOutCollection()
+	defer session.Close()
+	result = []model.NotificationOptOut{}
+	err = collection.Find(bson.M{"user_id": userId}).Sort("resource_id").All(&result)
+	return result, err
+}
+
+func (this *Controller) SetNotificationOptOut(ctx context.Context, optOut model.NotificationOptOut) (err error) {
+	_, span := this.startMongoSpan(ctx, "SetNotificationOptOut", this.config.NotificationOptOutCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOptOutCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"user_id": optOut.UserId, "resource_id": optOut.ResourceId}, optOut)
+	return err
+}
+
+func (this *Controller) DeleteNotificationOptOut(ctx context.Context, userId string, resourceId string) (err error) {
+	_, span := this.startMongoSpan(ctx, "DeleteNotificationOptOut", this.config.NotificationOptOutCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getOptOutCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"user_id": userId, "resource_id": resourceId})
+	return err
+}
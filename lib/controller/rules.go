@@ -0,0 +1,379 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	RuleTypeDevice = "device"
+	RuleTypeHub    = "hub"
+)
+
+// ruleTransitionHistory is the number of transitions kept per device or hub for RuleHistoryEnv
+const ruleTransitionHistory = 200
+
+// RuleEnv contains the variables available in NotificationRule conditions
+type RuleEnv struct {
+	Type                  string            `expr:"type"` //RuleTypeDevice or RuleTypeHub
+	Id                    string            `expr:"id"`
+	Name                  string            `expr:"name"`
+	Owner                 string            `expr:"owner"`
+	Connected             bool              `expr:"connected"`
+	Time                  time.Time         `expr:"time"`    //time of the transition in NotificationRuleTimezone
+	Hour                  int               `expr:"hour"`    //hour of Time
+	Weekday               string            `expr:"weekday"` //weekday of Time ("Mon", "Tue", ...)
+	Metadata              map[string]string `expr:"metadata"`
+	MonitorState          string            `expr:"monitor_connection_state"`
+	PreviousStateDuration time.Duration     `expr:"previous_state_duration"` //time since the previous transition; 0 for the first transition
+	Hub                   RuleHubEnv        `expr:"hub"`
+	History               RuleHistoryEnv    `expr:"history"`
+}
+
+// RuleHubEnv describes the hub of a device or the hub itself
+type RuleHubEnv struct {
+	Known  bool   `expr:"known"` //false for devices without hub metadata
+	Id     string `expr:"id"`
+	Name   string `expr:"name"`
+	Online bool   `expr:"online"`
+}
+
+// RuleHistoryEnv contains statistics of the latest transitions, including the current one
+type RuleHistoryEnv struct {
+	Disconnects1h  int `expr:"disconnects_1h"`
+	Disconnects24h int `expr:"disconnects_24h"`
+}
+
+type notificationRule struct {
+	config.NotificationRule
+	program  *vm.Program
+	cooldown time.Duration
+}
+
+// compileNotificationRules validates the rules and compiles their conditions
+func compileNotificationRules(rules []config.NotificationRule) (result []notificationRule, err error) {
+	names := map[string]bool{}
+	for _, rule := range rules {
+		if rule.Name == "" || strings.ContainsAny(rule.Name, ".$") {
+			return nil, fmt.Errorf("invalid notification rule name %q: must be non empty and without '.' or '$'", rule.Name)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate notification rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Type != "" && rule.Type != RuleTypeDevice && rule.Type != RuleTypeHub {
+			return nil, fmt.Errorf("invalid type of notification rule %v: expected %v or %v", rule.Name, RuleTypeDevice, RuleTypeHub)
+		}
+		compiled := notificationRule{NotificationRule: rule}
+		if rule.Cooldown != "" {
+			compiled.cooldown, err = ParseMonitorDuration(rule.Cooldown)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cooldown of notification rule %v: %w", rule.Name, err)
+			}
+		}
+		compiled.program, err = expr.Compile(rule.Condition, expr.Env(RuleEnv{}), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition of notification rule %v: %w", rule.Name, err)
+		}
+		result = append(result, compiled)
+	}
+	return result, nil
+}
+
+// ValidateNotificationRuleTemplates checks that the template of every rule (default RuleTemplate) is known
+func ValidateNotificationRuleTemplates(rules []config.NotificationRule, templates *NotificationTemplates) error {
+	for _, rule := range rules {
+		name := withDefault(rule.Template, RuleTemplate)
+		if !templates.Has(name) {
+			return fmt.Errorf("unknown template %v of notification rule %v", name, rule.Name)
+		}
+	}
+	return nil
+}
+
+// Matches evaluates the condition of rules with matching type
+func (this notificationRule) Matches(env RuleEnv) (bool, error) {
+	if this.Type != "" && this.Type != env.Type {
+		return false, nil
+	}
+	result, err := expr.Run(this.program, env)
+	if err != nil {
+		return false, err
+	}
+	matches, _ := result.(bool)
+	return matches, nil
+}
+
+// EvaluateNotificationRules returns the names of the rules matching env; the rules are validated like NotificationRules
+func EvaluateNotificationRules(rules []config.NotificationRule, env RuleEnv) (result []string, err error) {
+	compiled, err := compileNotificationRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range compiled {
+		matches, err := rule.Matches(env)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			result = append(result, rule.Name)
+		}
+	}
+	return result, nil
+}
+
+type ruleTransition struct {
+	Time      time.Time `bson:"time"`
+	Connected bool      `bson:"connected"`
+}
+
+// RuleState stores the latest transitions of a device or hub and the last notification per rule
+type RuleState struct {
+	Type        string               `bson:"type"`
+	Id          string               `bson:"id"`
+	Transitions []ruleTransition     `bson:"transitions"`
+	Notified    map[string]time.Time `bson:"notified"`
+}
+
+func (this *Controller) rulesEnabled() bool {
+	return len(this.rules) > 0 && this.config.NotificationRuleCollection != "" && this.config.NotificationRuleCollection != "-"
+}
+
+func (this *Controller) getRuleStateCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.getMongoDb()
+	collection = session.DB(this.config.MongoTable).C(this.config.NotificationRuleCollection)
+	err := collection.EnsureIndex(mgo.Index{Key: []string{"type", "id"}, Unique: true})
+	if err != nil {
+		log.Fatal("error on getRuleStateCollection id index: ", err)
+	}
+	return
+}
+
+// addRuleTransition stores the transition and returns the state before it
+func (this *Controller) addRuleTransition(ctx context.Context, ruleType string, id string, transition ruleTransition) (previous RuleState, err error) {
+	_, span := this.startMongoSpan(ctx, "addRuleTransition", this.config.NotificationRuleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getRuleStateCollection()
+	defer session.Close()
+	_, err = collection.Find(bson.M{"type": ruleType, "id": id}).Apply(mgo.Change{
+		Update: bson.M{
+			"$push": bson.M{"transitions": bson.M{
+				"$each":  []ruleTransition{transition},
+				"$sort":  bson.M{"time": 1},
+				"$slice": -ruleTransitionHistory,
+			}},
+		},
+		Upsert:    true,
+		ReturnNew: false,
+	}, &previous)
+	if errors.Is(err, mgo.ErrNotFound) {
+		return previous, nil
+	}
+	return previous, err
+}
+
+func (this *Controller) setRuleNotified(ctx context.Context, ruleType string, id string, rule string, t time.Time) (err error) {
+	_, span := this.startMongoSpan(ctx, "setRuleNotified", this.config.NotificationRuleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getRuleStateCollection()
+	defer session.Close()
+	return collection.Update(bson.M{"type": ruleType, "id": id}, bson.M{"$set": bson.M{"notified." + rule: t}})
+}
+
+func (this *Controller) removeRuleState(ctx context.Context, ruleType string, id string) (err error) {
+	_, span := this.startMongoSpan(ctx, "removeRuleState", this.config.NotificationRuleCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getRuleStateCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"type": ruleType, "id": id})
+	return err
+}
+
+func (this *Controller) getHubOnline(ctx context.Context, hubId string) (online bool, err error) {
+	_, span := this.startMongoSpan(ctx, "getHubOnline", this.config.HubStateCollection)
+	defer func() { tracing.End(span, err) }()
+	session, collection := this.getHubStateCollection()
+	defer session.Close()
+	count, err := collection.Find(bson.M{"gateway": hubId, "online": true}).Limit(1).Count()
+	return count > 0, err
+}
+
+// newRuleEnv stores the transition and returns the environment for rule conditions
+func (this *Controller) newRuleEnv(ctx context.Context, env RuleEnv) (result RuleEnv, notified map[string]time.Time, err error) {
+	previous, err := this.addRuleTransition(ctx, env.Type, env.Id, ruleTransition{Time: env.Time, Connected: env.Connected})
+	if err != nil {
+		return env, nil, err
+	}
+	transitions := append(previous.Transitions, ruleTransition{Time: env.Time, Connected: env.Connected})
+	if len(previous.Transitions) > 0 {
+		env.PreviousStateDuration = env.Time.Sub(previous.Transitions[len(previous.Transitions)-1].Time)
+	}
+	for _, transition := range transitions {
+		if transition.Connected {
+			continue
+		}
+		age := env.Time.Sub(transition.Time)
+		if age <= time.Hour {
+			env.History.Disconnects1h++
+		}
+		if age <= 24*time.Hour {
+			env.History.Disconnects24h++
+		}
+	}
+	env.Time = env.Time.In(this.ruleLocation)
+	env.Hour = env.Time.Hour()
+	env.Weekday = env.Time.Weekday().String()[:3]
+	return env, previous.Notified, nil
+}
+
+// handleDeviceRules evaluates the notification rules for a device transition
+func (this *Controller) handleDeviceRules(ctx context.Context, devicelog model.DeviceLog) {
+	if !this.rulesEnabled() {
+		return
+	}
+	env := RuleEnv{
+		Type:         RuleTypeDevice,
+		Id:           devicelog.Id,
+		Name:         devicelog.DeviceName,
+		Owner:        devicelog.DeviceOwner,
+		Connected:    devicelog.Connected,
+		Time:         devicelog.Time,
+		Metadata:     devicelog.Metadata,
+		MonitorState: devicelog.MonitorConnectionState,
+	}
+	hub, found, err := this.getHubMetadataByDevice(ctx, devicelog.Id)
+	if err != nil {
+		log.Println("WARNING: unable to get hub of device for notification rules", devicelog.Id, err)
+	}
+	if found {
+		env.Hub = RuleHubEnv{Known: true, Id: hub.HubId, Name: hub.Name}
+		env.Hub.Online, err = this.getHubOnline(ctx, hub.HubId)
+		if err != nil {
+			log.Println("WARNING: unable to get hub state for notification rules", hub.HubId, err)
+		}
+	}
+	env, notified, err := this.newRuleEnv(ctx, env)
+	if err != nil {
+		log.Println("ERROR: unable to store transition for notification rules", devicelog.Id, err)
+		return
+	}
+	targets := map[string]string{model.MaintenanceScopeDevice: devicelog.Id, model.MaintenanceScopeHub: env.Hub.Id, model.MaintenanceScopeOwner: devicelog.DeviceOwner}
+	this.applyRules(ctx, env, notified, targets, this.deviceResource(devicelog), func() NotificationTemplateData {
+		return this.getDeviceTemplateData(ctx, devicelog)
+	}, func(decision string, reason string) {
+		this.auditNotification(ctx, devicelog, decision, reason, "", 0)
+	})
+}
+
+// handleHubRules evaluates the notification rules for a hub transition
+func (this *Controller) handleHubRules(ctx context.Context, hublog model.HubLog) {
+	if !this.rulesEnabled() {
+		return
+	}
+	hub, err := this.getHubOfflineInfo(ctx, hublog)
+	if err != nil {
+		log.Println("WARNING: unable to get hub metadata for notification rules", hublog.Id, err)
+	}
+	env, notified, err := this.newRuleEnv(ctx, RuleEnv{
+		Type:         RuleTypeHub,
+		Id:           hub.HubId,
+		Name:         hub.HubName,
+		Owner:        hub.HubOwner,
+		Connected:    hublog.Connected,
+		Time:         hublog.Time,
+		Metadata:     hub.Metadata,
+		MonitorState: hub.MonitorConnectionState,
+		Hub:          RuleHubEnv{Known: true, Id: hub.HubId, Name: hub.HubName, Online: hublog.Connected},
+	})
+	if err != nil {
+		log.Println("ERROR: unable to store transition for notification rules", hublog.Id, err)
+		return
+	}
+	targets := map[string]string{model.MaintenanceScopeHub: hub.HubId, model.MaintenanceScopeOwner: hub.HubOwner}
+	this.applyRules(ctx, env, notified, targets, this.hubResource(hub), func() NotificationTemplateData {
+		return getHubTemplateData(hub)
+	}, func(decision string, reason string) {})
+}
+
+// applyRules notifies the recipients of resource for every matching rule outside its cooldown.
+// rules are not evaluated for old messages and during maintenance windows.
+func (this *Controller) applyRules(ctx context.Context, env RuleEnv, notified map[string]time.Time, maintenanceTargets map[string]string, resource NotificationResource, getData func() NotificationTemplateData, audit func(decision string, reason string)) {
+	if env.Owner == "" || time.Since(env.Time) > time.Hour {
+		return
+	}
+	var action *string
+	for _, rule := range this.rules {
+		matches, err := rule.Matches(env)
+		if err != nil {
+			log.Println("ERROR: unable to evaluate notification rule", rule.Name, env.Id, err)
+			continue
+		}
+		if !matches {
+			continue
+		}
+		if last, ok := notified[rule.Name]; ok && env.Time.Sub(last) < rule.cooldown {
+			audit(model.NotificationDecisionSkipped, "rule "+rule.Name+": cooldown")
+			continue
+		}
+		if action == nil {
+			temp := this.getMaintenanceAction(ctx, maintenanceTargets)
+			action = &temp
+		}
+		if *action != "" {
+			audit(model.NotificationDecisionSuppressed, "rule "+rule.Name+": maintenance window")
+			continue
+		}
+		outcome, err := this.sendRuleNotification(ctx, rule, env, resource, getData())
+		if err != nil {
+			audit(model.NotificationDecisionFailed, "rule "+rule.Name+": "+err.Error())
+			log.Println("ERROR: sendRuleNotification()", rule.Name, env.Id, err)
+			continue
+		}
+		decision, reason := outcome.AuditDecision("rule " + rule.Name)
+		if decision != model.NotificationDecisionSent {
+			reason = "rule " + rule.Name + ": " + reason
+		}
+		audit(decision, reason)
+		err = this.setRuleNotified(ctx, env.Type, env.Id, rule.Name, env.Time)
+		if err != nil {
+			log.Println("ERROR: setRuleNotified()", err)
+		}
+	}
+}
+
+func (this *Controller) sendRuleNotification(ctx context.Context, rule notificationRule, env RuleEnv, resource NotificationResource, data NotificationTemplateData) (DeliveryOutcome, error) {
+	if this.config.Debug {
+		log.Printf("DEBUG: send rule %v notification for %#v\n", rule.Name, env)
+	}
+	data.Rule = rule.Name
+	data.State = map[bool]string{true: "online", false: "offline"}[env.Connected]
+	data.Duration = env.PreviousStateDuration.Round(this.roundTime).String()
+	return this.deliverResourceNotification(ctx, resource, withDefault(rule.Template, RuleTemplate), withDefault(rule.Topic, "notification_rule"), data, 0)
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var defaultTemplateFiles embed.FS
+
+const (
+	DeviceOfflineTemplate        = "device_offline"
+	DeviceOnlineTemplate         = "device_online"
+	DeviceOfflineDigestTemplate  = "device_offline_digest"
+	DeviceMonitorErrorTemplate   = "device_monitor_error"
+	DeviceMissedWakeupTemplate   = "device_missed_wakeup"
+	DeviceFlappingTemplate       = "device_flapping"
+	DeviceNeverConnectedTemplate = "device_never_connected"
+	RuleTemplate                 = "notification_rule"
+	HubOfflineTemplate           = "hub_offline"
+	HubMonitorErrorTemplate      = "hub_monitor_error"
+	OutageTemplate               = "outage"
+)
+
+const fallbackLocale = "en"
+
+// NotificationTemplateData contains the variables available in notification templates
+type NotificationTemplateData struct {
+	DeviceId           string
+	DeviceName         string
+	HubId              string
+	HubName            string
+	Owner              string
+	Recipient          string //user receiving the notification; the owner or a user the device or hub is shared with
+	Duration           string //rounded by RoundTime
+	OfflineSince       time.Time
+	Time               time.Time
+	Severity           string
+	Error              string
+	UnreachableDevices int
+	Devices            int
+	Disconnects        int    //disconnects within Duration of flapping devices
+	Rule               string //name of the matching notification rule
+	State              string //"online" or "offline"
+	Metadata           map[string]string
+	Entries            []NotificationTemplateData //digest entries
+	More               int                        //digest entries exceeding DigestMaxDevices
+}
+
+// NotificationTemplates renders notification titles and messages.
+// each template file <locale>/<name>.tmpl defines the templates "title" and "message".
+type NotificationTemplates struct {
+	templates     map[string]map[string]*template.Template //locale -> name -> template
+	defaultLocale string
+}
+
+// LoadNotificationTemplates loads the embedded default templates and overwrites them with the templates found in dir.
+// an empty dir or "-" only loads the default templates.
+func LoadNotificationTemplates(dir string, defaultLocale string) (result *NotificationTemplates, err error) {
+	if defaultLocale == "" || defaultLocale == "-" {
+		defaultLocale = fallbackLocale
+	}
+	result = &NotificationTemplates{templates: map[string]map[string]*template.Template{}, defaultLocale: normalizeLocale(defaultLocale)}
+	defaults, err := fs.Sub(defaultTemplateFiles, "templates")
+	if err != nil {
+		return nil, err
+	}
+	err = result.load(defaults)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" && dir != "-" {
+		err = result.load(os.DirFS(dir))
+		if err != nil {
+			return nil, fmt.Errorf("unable to load notification templates from %v: %w", dir, err)
+		}
+	}
+	return result, nil
+}
+
+func (this *NotificationTemplates) load(files fs.FS) error {
+	return fs.WalkDir(files, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(filePath) != ".tmpl" {
+			return nil
+		}
+		locale := normalizeLocale(path.Dir(filePath))
+		if locale == "." {
+			return nil
+		}
+		name := strings.TrimSuffix(path.Base(filePath), ".tmpl")
+		content, err := fs.ReadFile(files, filePath)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(string(content))
+		if err != nil {
+			return err
+		}
+		for _, required := range []string{"title", "message"} {
+			if tmpl.Lookup(required) == nil {
+				return fmt.Errorf("template %v is missing the %q definition", filePath, required)
+			}
+		}
+		if _, ok := this.templates[locale]; !ok {
+			this.templates[locale] = map[string]*template.Template{}
+		}
+		this.templates[locale][name] = tmpl
+		return nil
+	})
+}
+
+// Render uses the template of the first locale containing name,
+// checking locale (e.g. "de-AT"), its language ("de"), the default locale and "en"
+func (this *NotificationTemplates) Render(name string, locale string, data NotificationTemplateData) (title string, message string, err error) {
+	tmpl, ok := this.find(name, locale)
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification template %v", name)
+	}
+	title, err = execute(tmpl, "title", data)
+	if err != nil {
+		return "", "", err
+	}
+	message, err = execute(tmpl, "message", data)
+	if err != nil {
+		return "", "", err
+	}
+	return title, message, nil
+}
+
+// Has checks if the template name can be rendered for every locale, i.e. exists for the default locale or "en"
+func (this *NotificationTemplates) Has(name string) bool {
+	_, ok := this.find(name, this.defaultLocale)
+	return ok
+}
+
+func (this *NotificationTemplates) find(name string, locale string) (tmpl *template.Template, ok bool) {
+	locale = normalizeLocale(locale)
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, language, this.defaultLocale, fallbackLocale} {
+		tmpl, ok = this.templates[candidate][name]
+		if ok {
+			return tmpl, true
+		}
+	}
+	return nil, false
+}
+
+func execute(tmpl *template.Template, name string, data NotificationTemplateData) (string, error) {
+	buf := &bytes.Buffer{}
+	err := tmpl.ExecuteTemplate(buf, name, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -18,11 +18,30 @@ package lib
 
 import (
 	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/api"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
 )
 
 func Start(ctx context.Context, config config.Config, runtimeErrorHandler func(err error, consumer *consumer.Consumer)) error {
-	return consumer.Start(ctx, config, controller.New(config), runtimeErrorHandler)
+	return StartWithRegistry(ctx, config, listener.DefaultRegistry(), runtimeErrorHandler)
+}
+
+// StartWithRegistry starts the worker with the listeners of registry.
+// may be used to add custom listeners (e.g. for additional source topics) to listener.DefaultRegistry().
+func StartWithRegistry(ctx context.Context, config config.Config, registry *listener.Registry, runtimeErrorHandler func(err error, consumer *consumer.Consumer)) error {
+	err := tracing.Start(ctx, config)
+	if err != nil {
+		return err
+	}
+	control := controller.New(config)
+	control.Start(ctx)
+	err = api.Start(ctx, config, control)
+	if err != nil {
+		return err
+	}
+	return consumer.Start(ctx, config, registry, control, runtimeErrorHandler)
 }
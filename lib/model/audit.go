@@ -0,0 +1,56 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "time"
+
+// decisions of NotificationAuditEntry
+const (
+	NotificationDecisionSent         = "sent"
+	NotificationDecisionFailed       = "failed"
+	NotificationDecisionPending      = "pending"
+	NotificationDecisionSkipped      = "skipped"
+	NotificationDecisionMissingOwner = "missing_owner"
+	NotificationDecisionParseError   = "parse_error"
+	NotificationDecisionSuppressed   = "suppressed"
+	NotificationDecisionDeferred     = "deferred"
+)
+
+// NotificationAuditEntry records the notification decision for a device log message
+type NotificationAuditEntry struct {
+	Id           string     `json:"id" bson:"_id"`
+	DeviceId     string     `json:"device_id" bson:"device_id"`
+	Owner        string     `json:"owner" bson:"owner"`
+	Connected    bool       `json:"connected" bson:"connected"`
+	Decision     string     `json:"decision" bson:"decision"`
+	Reason       string     `json:"reason" bson:"reason"`
+	Level        string     `json:"level,omitempty" bson:"level,omitempty"` //monitor level key of sent, suppressed or deferred notifications
+	EventTime    time.Time  `json:"event_time" bson:"event_time"`           //time of the device log message
+	OfflineSince *time.Time `json:"offline_since,omitempty" bson:"offline_since,omitempty"`
+	Time         time.Time  `json:"time" bson:"time"` //time of the decision
+}
+
+// NotificationAuditQuery filters audit entries; empty fields are ignored
+type NotificationAuditQuery struct {
+	DeviceId string
+	Owner    string
+	Decision string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
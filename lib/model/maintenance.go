@@ -0,0 +1,179 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" //timezones of recurring schedules must be available in minimal container images
+)
+
+const (
+	MaintenanceScopeDevice = "device"
+	MaintenanceScopeHub    = "hub"
+	MaintenanceScopeOwner  = "owner"
+)
+
+const (
+	// MaintenanceActionSuppress drops notifications of thresholds crossed during the window
+	MaintenanceActionSuppress = "suppress"
+	// MaintenanceActionDefer delays notifications until the window is closed
+	MaintenanceActionDefer = "defer"
+)
+
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// MaintenanceWindow suppresses or defers offline notifications of a device, hub or all devices and hubs of an owner.
+// a window is either a one-off range (Start to End) or a recurring Schedule, optionally limited by Start and End.
+type MaintenanceWindow struct {
+	Id          string             `json:"id" bson:"id"`
+	Scope       string             `json:"scope" bson:"scope"`
+	TargetId    string             `json:"target_id" bson:"target_id"`
+	Description string             `json:"description,omitempty" bson:"description"`
+	Action      string             `json:"action,omitempty" bson:"action"`
+	Start       *time.Time         `json:"start,omitempty" bson:"start,omitempty"`
+	End         *time.Time         `json:"end,omitempty" bson:"end,omitempty"`
+	Schedule    *RecurringSchedule `json:"schedule,omitempty" bson:"schedule,omitempty"`
+}
+
+// RecurringSchedule is active from StartTime to EndTime ("15:04") on Weekdays ("mon", ...; empty for every day) in Timezone.
+// an EndTime before or equal to StartTime ends on the next day (e.g. 22:00 to 06:00).
+type RecurringSchedule struct {
+	Weekdays  []string `json:"weekdays,omitempty" bson:"weekdays"`
+	StartTime string   `json:"start_time" bson:"start_time"`
+	EndTime   string   `json:"end_time" bson:"end_time"`
+	Timezone  string   `json:"timezone,omitempty" bson:"timezone"`
+}
+
+func (this MaintenanceWindow) Validate() error {
+	if this.Id == "" {
+		return errors.New("missing id")
+	}
+	if !slices.Contains([]string{MaintenanceScopeDevice, MaintenanceScopeHub, MaintenanceScopeOwner}, this.Scope) {
+		return fmt.Errorf("invalid scope %q; allowed are device, hub and owner", this.Scope)
+	}
+	if this.TargetId == "" {
+		return errors.New("missing target_id")
+	}
+	if this.Action != "" && this.Action != MaintenanceActionSuppress && this.Action != MaintenanceActionDefer {
+		return fmt.Errorf("invalid action %q; allowed are suppress and defer", this.Action)
+	}
+	if this.Start != nil && this.End != nil && !this.End.After(*this.Start) {
+		return errors.New("end must be after start")
+	}
+	if this.Schedule == nil {
+		if this.Start == nil || this.End == nil {
+			return errors.New("one-off windows need start and end")
+		}
+		return nil
+	}
+	return this.Schedule.Validate()
+}
+
+func (this RecurringSchedule) Validate() error {
+	for _, day := range this.Weekdays {
+		if !slices.Contains(Weekdays, strings.ToLower(day)) {
+			return fmt.Errorf("invalid weekday %q; allowed are %v", day, strings.Join(Weekdays, ", "))
+		}
+	}
+	_, err := time.Parse("15:04", this.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid start_time %q; expected format is 15:04", this.StartTime)
+	}
+	_, err = time.Parse("15:04", this.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid end_time %q; expected format is 15:04", this.EndTime)
+	}
+	_, err = time.LoadLocation(this.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", this.Timezone, err)
+	}
+	return nil
+}
+
+// GetAction returns Action with MaintenanceActionSuppress as default
+func (this MaintenanceWindow) GetAction() string {
+	if this.Action == "" {
+		return MaintenanceActionSuppress
+	}
+	return this.Action
+}
+
+// Active checks if the window contains t
+func (this MaintenanceWindow) Active(t time.Time) bool {
+	if this.Start != nil && t.Before(*this.Start) {
+		return false
+	}
+	if this.End != nil && !t.Before(*this.End) {
+		return false
+	}
+	if this.Schedule == nil {
+		return this.Start != nil && this.End != nil
+	}
+	return this.Schedule.Active(t)
+}
+
+// Active checks if an occurrence of the schedule, starting on the day of t or the day before, contains t
+func (this RecurringSchedule) Active(t time.Time) bool {
+	_, active := this.ActiveUntil(t)
+	return active
+}
+
+// ActiveUntil returns the end of the occurrence containing t, if the schedule is active at t
+func (this RecurringSchedule) ActiveUntil(t time.Time) (end time.Time, active bool) {
+	location, err := time.LoadLocation(this.Timezone)
+	if err != nil {
+		return end, false
+	}
+	startTime, err := time.Parse("15:04", this.StartTime)
+	if err != nil {
+		return end, false
+	}
+	endTime, err := time.Parse("15:04", this.EndTime)
+	if err != nil {
+		return end, false
+	}
+	local := t.In(location)
+	for _, offset := range []int{0, -1} {
+		year, month, day := local.AddDate(0, 0, offset).Date()
+		start := time.Date(year, month, day, startTime.Hour(), startTime.Minute(), 0, 0, location)
+		endDay := day
+		if !endTime.After(startTime) {
+			endDay = day + 1
+		}
+		end = time.Date(year, month, endDay, endTime.Hour(), endTime.Minute(), 0, 0, location)
+		if !this.onWeekday(start.Weekday()) {
+			continue
+		}
+		if !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (this RecurringSchedule) onWeekday(weekday time.Weekday) bool {
+	if len(this.Weekdays) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(this.Weekdays, func(day string) bool {
+		return strings.EqualFold(day, Weekdays[weekday])
+	})
+}
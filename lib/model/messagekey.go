@@ -0,0 +1,32 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "context"
+
+type messageKeyContextKey struct{}
+
+// WithMessageKey attaches the idempotency key of the consumed message to ctx
+func WithMessageKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, messageKeyContextKey{}, key)
+}
+
+// MessageKeyFromContext returns the idempotency key of the consumed message or "" outside of message processing
+func MessageKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(messageKeyContextKey{}).(string)
+	return key
+}
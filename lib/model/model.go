@@ -19,28 +19,76 @@ package model
 import "time"
 
 type HubLog struct {
-	Id        string    `json:"id"`
-	Connected bool      `json:"connected"`
-	Time      time.Time `json:"time"`
+	Id                     string            `json:"id"`
+	Connected              bool              `json:"connected"`
+	Time                   time.Time         `json:"time"`
+	MonitorConnectionState string            `json:"monitor_connection_state"`
+	HubOwner               string            `json:"hub_owner"`
+	HubName                string            `json:"hub_name"`
+	Locale                 string            `json:"locale,omitempty"`
+	NotificationChannel    string            `json:"notification_channel,omitempty"`
+	Metadata               map[string]string `json:"metadata,omitempty"`
 }
 
 type DeviceLog struct {
-	Id                     string    `json:"id"`
-	Connected              bool      `json:"connected"`
-	Time                   time.Time `json:"time"`
-	MonitorConnectionState string    `json:"monitor_connection_state"`
-	DeviceOwner            string    `json:"device_owner"`
-	DeviceName             string    `json:"device_name"`
+	Id                     string            `json:"id"`
+	Connected              bool              `json:"connected"`
+	Time                   time.Time         `json:"time"`
+	MonitorConnectionState string            `json:"monitor_connection_state"`
+	DeviceOwner            string            `json:"device_owner"`
+	DeviceName             string            `json:"device_name"`
+	NotifyOnReconnect      string            `json:"notify_on_reconnect,omitempty"`
+	DutyCycle              string            `json:"duty_cycle,omitempty"`
+	MonitorFlapping        string            `json:"monitor_flapping,omitempty"`
+	Locale                 string            `json:"locale,omitempty"`
+	NotificationChannel    string            `json:"notification_channel,omitempty"`
+	Metadata               map[string]string `json:"metadata,omitempty"`
 }
 
 type DeviceCommand struct {
 	Command string `json:"command"`
 	Id      string `json:"id"`
 	Owner   string `json:"owner"`
+	Device  Device `json:"device"`
+}
+
+type Device struct {
+	Id         string      `json:"id"`
+	LocalId    string      `json:"local_id"`
+	Name       string      `json:"name"`
+	OwnerId    string      `json:"owner_id"`
+	Attributes []Attribute `json:"attributes,omitempty"`
 }
 
 type HubCommand struct {
 	Command string `json:"command"`
 	Id      string `json:"id"`
 	Owner   string `json:"owner"`
+	Hub     Hub    `json:"hub"`
+}
+
+type Hub struct {
+	Id             string      `json:"id"`
+	Name           string      `json:"name"`
+	Hash           string      `json:"hash"`
+	DeviceLocalIds []string    `json:"device_local_ids"`
+	DeviceIds      []string    `json:"device_ids"`
+	OwnerId        string      `json:"owner_id"`
+	Attributes     []Attribute `json:"attributes,omitempty"`
+}
+
+type Attribute struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// GetAttribute returns the value of the first attribute with key
+func GetAttribute(attributes []Attribute, key string) (value string, found bool) {
+	for _, attribute := range attributes {
+		if attribute.Key == key {
+			return attribute.Value, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,26 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+// OptOutAllResources is the ResourceId of opt-outs for all devices and hubs of a user
+const OptOutAllResources = "*"
+
+// NotificationOptOut stops the notifications of a device or hub (ResourceId) for a user
+type NotificationOptOut struct {
+	UserId     string `json:"user_id" bson:"user_id"`
+	ResourceId string `json:"resource_id" bson:"resource_id"`
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+)
+
+// NotificationPreferences are the personal notification settings of a user.
+// Channel and Locale are used if the device or hub has no own notification_channel or locale attribute.
+// Delivery selects between DeliveryImmediate and DeliveryDigest (default) for offline notifications, if digests are enabled.
+// notifications during QuietHours are delayed until the quiet hours end; notifications about MutedDevices (device or hub ids) are dropped.
+type NotificationPreferences struct {
+	UserId       string             `json:"user_id" bson:"user_id"`
+	Channel      string             `json:"channel,omitempty" bson:"channel"`
+	Delivery     string             `json:"delivery,omitempty" bson:"delivery"`
+	QuietHours   *RecurringSchedule `json:"quiet_hours,omitempty" bson:"quiet_hours,omitempty"`
+	Locale       string             `json:"locale,omitempty" bson:"locale"`
+	MutedDevices []string           `json:"muted_devices,omitempty" bson:"muted_devices"`
+}
+
+func (this NotificationPreferences) Validate() error {
+	if this.UserId == "" {
+		return errors.New("missing user_id")
+	}
+	if this.Delivery != "" && this.Delivery != DeliveryImmediate && this.Delivery != DeliveryDigest {
+		return fmt.Errorf("invalid delivery %q; allowed are immediate and digest", this.Delivery)
+	}
+	if slices.Contains(this.MutedDevices, "") {
+		return errors.New("muted_devices contains an empty id")
+	}
+	if this.QuietHours != nil {
+		err := this.QuietHours.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid quiet_hours: %w", err)
+		}
+	}
+	return nil
+}
+
+// Muted checks if notifications about the device or hub id are muted
+func (this NotificationPreferences) Muted(id string) bool {
+	return id != "" && slices.Contains(this.MutedDevices, id)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMqttTopic = "notifications/{userId}"
+
+// MqttNotifier publishes notifications as json.
+// the topic is the address of the channel spec (e.g. "mqtt:alerts/site-a") or the Topic option;
+// the placeholders {userId} and {topic} are replaced with the notification fields.
+type MqttNotifier struct {
+	options MqttOptions
+	client  paho.Client
+	mux     sync.Mutex
+}
+
+type MqttOptions struct {
+	Broker   string `json:"broker"`
+	ClientId string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Topic    string `json:"topic"`
+	Qos      byte   `json:"qos"`
+	Retained bool   `json:"retained"`
+}
+
+func NewMqttNotifier(options MqttOptions) *MqttNotifier {
+	if options.Topic == "" {
+		options.Topic = DefaultMqttTopic
+	}
+	if options.ClientId == "" {
+		options.ClientId = "connection-log-worker-notifier"
+	}
+	return &MqttNotifier{options: options}
+}
+
+func NewMqttNotifierFromOptions(_ config.Config, options json.RawMessage) (Notifier, error) {
+	opt := MqttOptions{}
+	err := parseOptions(options, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Broker == "" {
+		return nil, errors.New("missing mqtt broker")
+	}
+	return NewMqttNotifier(opt), nil
+}
+
+// getClient connects lazily to not block the worker start by an unavailable broker
+func (this *MqttNotifier) getClient() (paho.Client, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.client != nil {
+		return this.client, nil
+	}
+	options := paho.NewClientOptions().
+		AddBroker(this.options.Broker).
+		SetClientID(this.options.ClientId).
+		SetUsername(this.options.Username).
+		SetPassword(this.options.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(5 * time.Second)
+	client := paho.NewClient(options)
+	token := client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		return nil, errors.New("timeout on mqtt connect")
+	}
+	if token.Error() != nil {
+		return nil, token.Error()
+	}
+	this.client = client
+	return client, nil
+}
+
+func (this *MqttNotifier) Notify(ctx context.Context, notification Notification) (err error) {
+	topic := notification.Address
+	if topic == "" {
+		topic = this.options.Topic
+	}
+	topic = strings.NewReplacer("{userId}", notification.UserId, "{topic}", notification.Topic).Replace(topic)
+	_, span := tracer.Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer))
+	defer func() { tracing.End(span, err) }()
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	client, err := this.getClient()
+	if err != nil {
+		return err
+	}
+	token := client.Publish(topic, this.options.Qos, this.options.Retained, payload)
+	if !token.WaitTimeout(10 * time.Second) {
+		return errors.New("timeout on mqtt publish")
+	}
+	return token.Error()
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"go.opentelemetry.io/otel"
+	"strings"
+	"time"
+)
+
+var tracer = otel.Tracer("github.com/SENERGY-Platform/connection-log-worker/lib/notifier")
+
+const (
+	ServiceType = "notifier"
+	WebhookType = "webhook"
+	SmtpType    = "smtp"
+	MqttType    = "mqtt"
+)
+
+// DefaultChannel is the name of the channel using the notifier service at config.NotificationUrl
+const DefaultChannel = "notifier"
+
+type Notification struct {
+	UserId  string `json:"userId" bson:"userId"`
+	Title   string `json:"title" bson:"title"`
+	Message string `json:"message" bson:"message"`
+	Topic   string `json:"topic" bson:"topic"`
+
+	// IgnoreDuplicatesWithin asks the channel to drop equal notifications sent within the duration, if supported
+	IgnoreDuplicatesWithin time.Duration `json:"-" bson:"-"`
+
+	// Address is the channel specific recipient (e.g. e-mail address or mqtt topic) selected by the channel spec
+	Address string `json:"-" bson:"-"`
+
+	// Id identifies the notification for receivers deduplicating retried deliveries, if supported by the channel
+	Id string `json:"-" bson:"-"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+type NotifierFunc func(ctx context.Context, notification Notification) error
+
+func (this NotifierFunc) Notify(ctx context.Context, notification Notification) error {
+	return this(ctx, notification)
+}
+
+// Factories create notifiers of a type from the config.NotificationChannels options
+var Factories = map[string]func(config config.Config, options json.RawMessage) (Notifier, error){
+	ServiceType: NewServiceNotifierFromOptions,
+	WebhookType: NewWebhookNotifierFromOptions,
+	SmtpType:    NewSmtpNotifierFromOptions,
+	MqttType:    NewMqttNotifierFromOptions,
+}
+
+// Channels sends notifications to the channels selected by a channel spec
+type Channels struct {
+	channels       map[string]Notifier
+	defaultChannel string
+}
+
+// New creates the channels of config.NotificationChannels and the DefaultChannel
+func New(config config.Config) (result *Channels, err error) {
+	result = &Channels{
+		channels:       map[string]Notifier{DefaultChannel: NewServiceNotifier(config.NotificationUrl, auth.New(config))},
+		defaultChannel: config.DefaultNotificationChannel,
+	}
+	if result.defaultChannel == "" || result.defaultChannel == "-" {
+		result.defaultChannel = DefaultChannel
+	}
+	for name, channelConfig := range config.NotificationChannels {
+		factory, ok := Factories[channelConfig.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown notification channel type %q of %q", channelConfig.Type, name)
+		}
+		result.channels[name], err = factory(config, channelConfig.Options)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create notification channel %q: %w", name, err)
+		}
+	}
+	return result, nil
+}
+
+// Set adds or replaces a channel
+func (this *Channels) Set(name string, notifier Notifier) {
+	this.channels[name] = notifier
+}
+
+// Targets splits spec into its channel elements ("name" or "name:address"); an empty spec selects the default channel
+func (this *Channels) Targets(spec string) (result []string) {
+	if strings.TrimSpace(spec) == "" {
+		spec = this.defaultChannel
+	}
+	for _, element := range strings.Split(spec, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// Notify sends the notification to every channel of spec.
+// spec is a comma separated list of channel names with optional address (e.g. "notifier,smtp:user@example.com").
+// an empty spec selects the default channel.
+func (this *Channels) Notify(ctx context.Context, spec string, notification Notification) (err error) {
+	for _, element := range this.Targets(spec) {
+		name, address, _ := strings.Cut(element, ":")
+		channel, ok := this.channels[name]
+		if !ok {
+			err = errors.Join(err, fmt.Errorf("unknown notification channel %q", name))
+			continue
+		}
+		temp := notification
+		temp.Address = address
+		err = errors.Join(err, channel.Notify(ctx, temp))
+	}
+	return err
+}
+
+func parseOptions(options json.RawMessage, result interface{}) error {
+	if len(options) == 0 {
+		return nil
+	}
+	return json.Unmarshal(options, result)
+}
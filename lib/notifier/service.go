@@ -0,0 +1,110 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ServiceNotifier sends notifications to the notifier service (POST {url}/notifications).
+// requests are authenticated with client-credentials tokens if tokens is not nil.
+type ServiceNotifier struct {
+	url    string
+	tokens *auth.Tokens
+}
+
+func NewServiceNotifier(url string, tokens *auth.Tokens) *ServiceNotifier {
+	return &ServiceNotifier{url: url, tokens: tokens}
+}
+
+type ServiceOptions struct {
+	Url string `json:"url"`
+}
+
+// NewServiceNotifierFromOptions uses config.NotificationUrl if no url is set
+func NewServiceNotifierFromOptions(config config.Config, options json.RawMessage) (Notifier, error) {
+	opt := ServiceOptions{Url: config.NotificationUrl}
+	err := parseOptions(options, &opt)
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceNotifier(opt.Url, auth.New(config)), nil
+}
+
+func (this *ServiceNotifier) Notify(ctx context.Context, notification Notification) (err error) {
+	endpoint := this.url + "/notifications"
+	if notification.IgnoreDuplicatesWithin > 0 {
+		endpoint = endpoint + "?ignore_duplicates_within_seconds=" + strconv.FormatInt(int64(notification.IgnoreDuplicatesWithin.Seconds()), 10)
+	}
+	ctx, span := tracer.Start(ctx, "notifier POST /notifications", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.HTTPRequestMethodKey.String(http.MethodPost),
+		semconv.URLFull(endpoint),
+	))
+	defer func() { tracing.End(span, err) }()
+	header := http.Header{}
+	if this.tokens != nil {
+		token, err := this.tokens.Authorization("")
+		if err != nil {
+			return fmt.Errorf("unable to get notifier token: %w", err)
+		}
+		header.Set("Authorization", token)
+	}
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(notification)
+	if err != nil {
+		return err
+	}
+	return post(ctx, endpoint, b, header)
+}
+
+func post(ctx context.Context, endpoint string, body io.Reader, header http.Header) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respMsg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected response status from %v: %v %v", endpoint, resp.Status, string(respMsg))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SmtpNotifier sends notifications as e-mail.
+// the recipient is the address of the channel spec (e.g. "smtp:user@example.com") or the entry of the user in Recipients.
+type SmtpNotifier struct {
+	options SmtpOptions
+}
+
+type SmtpOptions struct {
+	Host       string            `json:"host"`
+	Port       int               `json:"port"`
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	From       string            `json:"from"`
+	Recipients map[string]string `json:"recipients"` //user id -> e-mail address
+}
+
+func NewSmtpNotifier(options SmtpOptions) *SmtpNotifier {
+	if options.Port == 0 {
+		options.Port = 25
+	}
+	return &SmtpNotifier{options: options}
+}
+
+func NewSmtpNotifierFromOptions(_ config.Config, options json.RawMessage) (Notifier, error) {
+	opt := SmtpOptions{}
+	err := parseOptions(options, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Host == "" || opt.From == "" {
+		return nil, errors.New("missing smtp host or from address")
+	}
+	return NewSmtpNotifier(opt), nil
+}
+
+func (this *SmtpNotifier) Notify(ctx context.Context, notification Notification) (err error) {
+	addr := net.JoinHostPort(this.options.Host, strconv.Itoa(this.options.Port))
+	_, span := tracer.Start(ctx, "smtp send", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.ServerAddress(this.options.Host),
+		semconv.ServerPort(this.options.Port),
+	))
+	defer func() { tracing.End(span, err) }()
+	recipient := notification.Address
+	if recipient == "" {
+		recipient = this.options.Recipients[notification.UserId]
+	}
+	if recipient == "" {
+		return fmt.Errorf("no e-mail address for user %v", notification.UserId)
+	}
+	var auth smtp.Auth
+	if this.options.Username != "" {
+		auth = smtp.PlainAuth("", this.options.Username, this.options.Password, this.options.Host)
+	}
+	return smtp.SendMail(addr, auth, this.options.From, []string{recipient}, this.message(recipient, notification))
+}
+
+func (this *SmtpNotifier) message(recipient string, notification Notification) []byte {
+	buf := &bytes.Buffer{}
+	header := [][2]string{
+		{"From", this.options.From},
+		{"To", recipient},
+		{"Subject", mime.QEncoding.Encode("utf-8", notification.Title)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, h := range header {
+		buf.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(notification.Message, "\r\n", "\n"), "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
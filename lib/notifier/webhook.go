@@ -0,0 +1,97 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const WebhookSignatureHeader = "X-Signature-256"
+const WebhookTimestampHeader = "X-Timestamp"
+const WebhookIdHeader = "X-Notification-Id"
+
+// WebhookNotifier posts notifications as json to a url.
+// if a secret is set, the payload is signed with HMAC-SHA256 over "<timestamp>.<body>";
+// the signature is sent as "sha256=<hex>" in the X-Signature-256 header and the unix timestamp in the X-Timestamp header.
+type WebhookNotifier struct {
+	url    string
+	secret string
+}
+
+type WebhookOptions struct {
+	Url    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+func NewWebhookNotifier(url string, secret string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret}
+}
+
+func NewWebhookNotifierFromOptions(_ config.Config, options json.RawMessage) (Notifier, error) {
+	opt := WebhookOptions{}
+	err := parseOptions(options, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Url == "" {
+		return nil, errors.New("missing webhook url")
+	}
+	return NewWebhookNotifier(opt.Url, opt.Secret), nil
+}
+
+func (this *WebhookNotifier) Notify(ctx context.Context, notification Notification) (err error) {
+	ctx, span := tracer.Start(ctx, "webhook POST", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.HTTPRequestMethodKey.String(http.MethodPost),
+		semconv.URLFull(this.url),
+	))
+	defer func() { tracing.End(span, err) }()
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if notification.Id != "" {
+		header.Set(WebhookIdHeader, notification.Id)
+	}
+	if this.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(WebhookTimestampHeader, timestamp)
+		header.Set(WebhookSignatureHeader, SignWebhookPayload(this.secret, timestamp, body))
+	}
+	return post(ctx, this.url, bytes.NewReader(body), header)
+}
+
+// SignWebhookPayload returns the X-Signature-256 header value for the payload
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -20,21 +20,54 @@ import (
 	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"log"
+	"strconv"
+	"strings"
 )
 
-func Start(ctx context.Context, config config.Config, control listener.Controller, runtimeErrorHandler func(err error, consumer *Consumer)) (err error) {
-	for _, factory := range listener.Factories {
-		topic, handler, err := factory(config, control)
+var tracer = otel.Tracer("github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer")
+
+func Start(ctx context.Context, config config.Config, registry *listener.Registry, control listener.Controller, runtimeErrorHandler func(err error, consumer *Consumer)) (err error) {
+	listeners, err := registry.Enabled(config)
+	if err != nil {
+		return err
+	}
+	deduplicator, deduplicate := control.(listener.Deduplicator)
+	deduplicate = deduplicate && config.ProcessedEventCollection != "" && config.ProcessedEventCollection != "-"
+	control, err = listener.ChainFromConfig(config, control)
+	if err != nil {
+		return err
+	}
+	for _, l := range listeners {
+		topic := l.Topic
+		handler, err := l.Factory(config, topic, control)
 		if err != nil {
-			log.Println("ERROR: listener.factory", topic, err)
+			log.Println("ERROR: listener.factory", l.Name, topic, err)
 			return err
 		}
-		err = RunConsumer(ctx, config.KafkaUrl, config.KafkaGroupId, topic, config.InitTopics, func(topic string, msg []byte) error {
+		if deduplicate {
+			handler = listener.Deduplicate(deduplicator, handler, config.Debug)
+		}
+		err = RunConsumer(ctx, config.KafkaUrl, config.KafkaGroupId, topic, config.InitTopics, func(ctx context.Context, msg kafka.Message) (err error) {
 			if config.Debug {
-				log.Println("DEBUG: consume", topic, string(msg))
+				log.Println("DEBUG: consume", msg.Topic, string(msg.Value))
 			}
-			return handler(msg)
+			listenerMsg := toListenerMessage(msg)
+			ctx, span := tracer.Start(tracing.Extract(ctx, listenerMsg.Headers), msg.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					semconv.MessagingSystemKafka,
+					semconv.MessagingDestinationName(msg.Topic),
+					semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+					semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+				))
+			defer func() { tracing.End(span, err) }()
+			return handler(ctx, listenerMsg)
 		}, runtimeErrorHandler)
 		if err != nil {
 			return err
@@ -42,3 +75,17 @@ func Start(ctx context.Context, config config.Config, control listener.Controlle
 	}
 	return err
 }
+
+func toListenerMessage(msg kafka.Message) listener.Message {
+	headers := map[string]string{}
+	for _, header := range msg.Headers {
+		headers[strings.ToLower(header.Key)] = string(header.Value)
+	}
+	return listener.Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Value:     msg.Value,
+		Headers:   headers,
+	}
+}
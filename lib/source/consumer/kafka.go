@@ -28,7 +28,7 @@ import (
 	"time"
 )
 
-func RunConsumer(ctx context.Context, zk string, groupid string, topic string, initTopic bool, listener func(topic string, msg []byte) error, errorhandler func(err error, consumer *Consumer)) (err error) {
+func RunConsumer(ctx context.Context, zk string, groupid string, topic string, initTopic bool, listener func(ctx context.Context, msg kafka.Message) error, errorhandler func(err error, consumer *Consumer)) (err error) {
 	consumer := &Consumer{groupId: groupid, zkUrl: zk, topic: topic, listener: listener, errorhandler: errorhandler, ctx: ctx, initTopic: initTopic}
 	err = consumer.start()
 	return
@@ -41,7 +41,7 @@ type Consumer struct {
 	topic        string
 	ctx          context.Context
 	cancel       context.CancelFunc
-	listener     func(topic string, msg []byte) error
+	listener     func(ctx context.Context, msg kafka.Message) error
 	errorhandler func(err error, consumer *Consumer)
 	mux          sync.Mutex
 	initTopic    bool
@@ -89,7 +89,7 @@ func (this *Consumer) start() error {
 				}
 
 				err = retry(func() error {
-					return this.listener(m.Topic, m.Value)
+					return this.listener(this.ctx, m)
 				}, func(n int64) time.Duration {
 					return time.Duration(n) * time.Second
 				}, 10*time.Minute)
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+	"github.com/hamba/avro/v2"
+	"sync"
+)
+
+func init() {
+	PayloadDecoderFactories[EncodingAvro] = NewAvroDecoder
+}
+
+// AvroDecoder decodes messages in the Confluent avro wire format.
+// record fields are mapped to result by the json tags of its fields.
+type AvroDecoder struct {
+	registry *schemaregistry.Client
+	api      avro.API
+	mux      sync.Mutex
+	schemas  map[int]avro.Schema
+}
+
+func NewAvroDecoder(config config.Config) (PayloadDecoder, error) {
+	registry, err := getSchemaRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroDecoder{
+		registry: registry,
+		api:      avro.Config{TagKey: "json"}.Freeze(),
+		schemas:  map[int]avro.Schema{},
+	}, nil
+}
+
+func (this *AvroDecoder) Decode(msg []byte, result interface{}) error {
+	schemaId, payload, err := schemaregistry.SplitWireFormat(msg)
+	if err != nil {
+		return err
+	}
+	schema, err := this.getSchema(schemaId)
+	if err != nil {
+		return err
+	}
+	return this.api.Unmarshal(schema, payload, result)
+}
+
+func (this *AvroDecoder) getSchema(schemaId int) (result avro.Schema, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result, ok := this.schemas[schemaId]
+	if ok {
+		return result, nil
+	}
+	schema, err := this.registry.GetSchemaById(schemaId)
+	if err != nil {
+		return nil, err
+	}
+	if schema.SchemaType != schemaregistry.SchemaTypeAvro {
+		return nil, fmt.Errorf("schema %v is of type %v, expected %v", schemaId, schema.SchemaType, schemaregistry.SchemaTypeAvro)
+	}
+	result, err = avro.Parse(schema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	this.schemas[schemaId] = result
+	return result, nil
+}
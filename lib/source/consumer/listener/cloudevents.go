@@ -0,0 +1,114 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const cloudEventsContentType = "application/cloudevents+json"
+
+// EventMeta contains the CloudEvents context attributes of a consumed message.
+// for raw json payloads all fields are empty.
+type EventMeta struct {
+	Id      string
+	Source  string
+	Type    string
+	Subject string
+	Time    time.Time
+}
+
+type structuredCloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+}
+
+func isBinaryCloudEvent(msg Message) bool {
+	_, ok := msg.Headers["ce_specversion"]
+	return ok
+}
+
+func isStructuredCloudEvent(msg Message) bool {
+	if strings.HasPrefix(msg.Headers["content-type"], cloudEventsContentType) {
+		return true
+	}
+	probe := struct {
+		SpecVersion string `json:"specversion"`
+	}{}
+	err := json.Unmarshal(msg.Value, &probe)
+	return err == nil && probe.SpecVersion != ""
+}
+
+func getBinaryCloudEventMeta(msg Message) (meta EventMeta, err error) {
+	meta.Id = msg.Headers["ce_id"]
+	meta.Source = msg.Headers["ce_source"]
+	meta.Type = msg.Headers["ce_type"]
+	meta.Subject = msg.Headers["ce_subject"]
+	meta.Time, err = parseEventTime(msg.Headers["ce_time"])
+	return meta, err
+}
+
+func decodeStructuredCloudEvent(value []byte, result interface{}) (meta EventMeta, err error) {
+	event := structuredCloudEvent{}
+	err = json.Unmarshal(value, &event)
+	if err != nil {
+		return meta, err
+	}
+	if event.SpecVersion == "" {
+		return meta, errors.New("expected cloudevent but specversion is missing")
+	}
+	meta = EventMeta{
+		Id:      event.Id,
+		Source:  event.Source,
+		Type:    event.Type,
+		Subject: event.Subject,
+	}
+	meta.Time, err = parseEventTime(event.Time)
+	if err != nil {
+		return meta, err
+	}
+	data := []byte(event.Data)
+	if event.DataBase64 != "" {
+		data, err = base64.StdEncoding.DecodeString(event.DataBase64)
+		if err != nil {
+			return meta, err
+		}
+	}
+	if len(data) == 0 {
+		return meta, nil
+	}
+	err = json.Unmarshal(data, result)
+	return meta, err
+}
+
+func parseEventTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+	"strings"
+	"sync"
+)
+
+const (
+	EncodingAuto        = "auto"
+	EncodingJson        = "json"
+	EncodingCloudEvents = "cloudevents"
+	EncodingProtobuf    = "protobuf"
+	EncodingAvro        = "avro"
+)
+
+// PayloadDecoder decodes a message payload (without CloudEvents envelope) into result.
+type PayloadDecoder interface {
+	Decode(payload []byte, result interface{}) error
+}
+
+type PayloadDecoderFunc func(payload []byte, result interface{}) error
+
+func (this PayloadDecoderFunc) Decode(payload []byte, result interface{}) error {
+	return this(payload, result)
+}
+
+// PayloadDecoderFactories maps encodings, usable in config.TopicEncodings, to PayloadDecoder constructors.
+// additional encodings may be registered before the consumers are started.
+var PayloadDecoderFactories = map[string]func(config config.Config) (PayloadDecoder, error){
+	EncodingJson: func(config config.Config) (PayloadDecoder, error) {
+		return PayloadDecoderFunc(json.Unmarshal), nil
+	},
+}
+
+type Decoder struct {
+	topic    string
+	encoding string
+	payload  PayloadDecoder
+}
+
+// NewDecoder creates a Decoder for the encoding configured for topic in config.TopicEncodings.
+func NewDecoder(config config.Config, topic string) (decoder *Decoder, err error) {
+	decoder = &Decoder{topic: topic, encoding: getTopicEncoding(config, topic)}
+	factory, ok := PayloadDecoderFactories[decoder.encoding]
+	if decoder.encoding == EncodingAuto || decoder.encoding == EncodingCloudEvents {
+		factory, ok = PayloadDecoderFactories[EncodingJson]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %v for topic %v", decoder.encoding, topic)
+	}
+	decoder.payload, err = factory(config)
+	return decoder, err
+}
+
+func getTopicEncoding(config config.Config, topic string) string {
+	encoding, ok := config.TopicEncodings[topic]
+	if !ok || encoding == "" {
+		return EncodingAuto
+	}
+	return strings.ToLower(encoding)
+}
+
+// Decode unmarshals the payload of msg into result.
+// depending on the encoding of the topic the payload is expected as raw json,
+// as CloudEvent (structured json or kafka binary mode with ce_ headers), in a binary format like protobuf or avro
+// or is detected automatically. binary formats may be combined with CloudEvents in kafka binary mode.
+func (this *Decoder) Decode(msg Message, result interface{}) (meta EventMeta, err error) {
+	switch this.encoding {
+	case EncodingCloudEvents:
+		if isBinaryCloudEvent(msg) {
+			return this.decodeBinaryCloudEvent(msg, result)
+		}
+		return decodeStructuredCloudEvent(msg.Value, result)
+	case EncodingAuto:
+		if isBinaryCloudEvent(msg) {
+			return this.decodeBinaryCloudEvent(msg, result)
+		}
+		if isStructuredCloudEvent(msg) {
+			return decodeStructuredCloudEvent(msg.Value, result)
+		}
+		err = this.payload.Decode(msg.Value, result)
+		return meta, err
+	default:
+		if isBinaryCloudEvent(msg) {
+			return this.decodeBinaryCloudEvent(msg, result)
+		}
+		err = this.payload.Decode(msg.Value, result)
+		return meta, err
+	}
+}
+
+func (this *Decoder) decodeBinaryCloudEvent(msg Message, result interface{}) (meta EventMeta, err error) {
+	meta, err = getBinaryCloudEventMeta(msg)
+	if err != nil {
+		return meta, err
+	}
+	err = this.payload.Decode(msg.Value, result)
+	return meta, err
+}
+
+var schemaRegistries = map[string]*schemaregistry.Client{}
+var schemaRegistriesMux sync.Mutex
+
+// getSchemaRegistry returns a shared schema registry client for config.SchemaRegistryUrl,
+// so that decoders of different topics use the same schema cache.
+func getSchemaRegistry(config config.Config) (*schemaregistry.Client, error) {
+	if config.SchemaRegistryUrl == "" || config.SchemaRegistryUrl == "-" {
+		return nil, errors.New("missing SchemaRegistryUrl config")
+	}
+	schemaRegistriesMux.Lock()
+	defer schemaRegistriesMux.Unlock()
+	client, ok := schemaRegistries[config.SchemaRegistryUrl]
+	if !ok {
+		client = schemaregistry.New(config.SchemaRegistryUrl, auth.New(config))
+		schemaRegistries[config.SchemaRegistryUrl] = client
+	}
+	return client, nil
+}
@@ -17,22 +17,28 @@
 package listener
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
-func init() {
-	Factories = append(Factories, DeviceLogListenerFactory)
-}
-
-func DeviceLogListenerFactory(config config.Config, control Controller) (topic string, listener Listener, err error) {
-	return config.DeviceLogTopic, func(msg []byte) (err error) {
+func DeviceLogListenerFactory(config config.Config, topic string, control Controller) (listener Listener, err error) {
+	decoder, err := NewDecoder(config, topic)
+	if err != nil {
+		return listener, err
+	}
+	return func(ctx context.Context, msg Message) (err error) {
 		command := model.DeviceLog{}
-		err = json.Unmarshal(msg, &command)
+		meta, err := decoder.Decode(msg, &command)
 		if err != nil {
 			return
 		}
-		return control.LogDevice(command)
+		if meta.Subject != "" {
+			command.Id = meta.Subject
+		}
+		if !meta.Time.IsZero() {
+			command.Time = meta.Time
+		}
+		return control.LogDevice(ctx, command)
 	}, nil
 }
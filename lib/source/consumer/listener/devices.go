@@ -17,22 +17,25 @@
 package listener
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
-func init() {
-	Factories = append(Factories, DevicesListenerFactory)
-}
-
-func DevicesListenerFactory(config config.Config, control Controller) (topic string, listener Listener, err error) {
-	return config.DeviceTopic, func(msg []byte) (err error) {
+func DevicesListenerFactory(config config.Config, topic string, control Controller) (listener Listener, err error) {
+	decoder, err := NewDecoder(config, topic)
+	if err != nil {
+		return listener, err
+	}
+	return func(ctx context.Context, msg Message) (err error) {
 		command := model.DeviceCommand{}
-		err = json.Unmarshal(msg, &command)
+		meta, err := decoder.Decode(msg, &command)
 		if err != nil {
 			return
 		}
-		return control.UpdateDevice(command)
+		if meta.Subject != "" {
+			command.Id = meta.Subject
+		}
+		return control.UpdateDevice(ctx, command)
 	}, nil
 }
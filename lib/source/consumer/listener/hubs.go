@@ -17,22 +17,25 @@
 package listener
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
-func init() {
-	Factories = append(Factories, HubsListenerFactory)
-}
-
-func HubsListenerFactory(config config.Config, control Controller) (topic string, listener Listener, err error) {
-	return config.HubTopic, func(msg []byte) (err error) {
+func HubsListenerFactory(config config.Config, topic string, control Controller) (listener Listener, err error) {
+	decoder, err := NewDecoder(config, topic)
+	if err != nil {
+		return listener, err
+	}
+	return func(ctx context.Context, msg Message) (err error) {
 		command := model.HubCommand{}
-		err = json.Unmarshal(msg, &command)
+		meta, err := decoder.Decode(msg, &command)
 		if err != nil {
 			return
 		}
-		return control.UpdateHub(command)
+		if meta.Subject != "" {
+			command.Id = meta.Subject
+		}
+		return control.UpdateHub(ctx, command)
 	}, nil
 }
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"log"
+	"strconv"
+)
+
+// Deduplicator remembers which messages have already been processed.
+type Deduplicator interface {
+	IsProcessed(ctx context.Context, key string) (bool, error)
+	MarkProcessed(ctx context.Context, key string) error
+}
+
+// Deduplicate wraps listener so that messages with an already processed MessageKey are skipped.
+// messages are marked as processed after listener succeeded; a crash or error between the side effects of listener and
+// MarkProcessed leads to a repeated execution. the key is passed to listener with model.WithMessageKey,
+// so that side effects can be recorded together with the key (e.g. outbox entries) and be skipped on the repetition.
+func Deduplicate(deduplicator Deduplicator, listener Listener, debug bool) Listener {
+	return func(ctx context.Context, msg Message) (err error) {
+		key := MessageKey(msg)
+		ctx = model.WithMessageKey(ctx, key)
+		processed, err := deduplicator.IsProcessed(ctx, key)
+		if err != nil {
+			return err
+		}
+		if processed {
+			if debug {
+				log.Println("DEBUG: skip already processed message", key)
+			}
+			return nil
+		}
+		err = listener(ctx, msg)
+		if err != nil {
+			return err
+		}
+		return deduplicator.MarkProcessed(ctx, key)
+	}
+}
+
+// MessageKey identifies msg by the producer supplied CloudEvents id (and source) if available,
+// otherwise by its topic, partition and offset.
+func MessageKey(msg Message) string {
+	if id := msg.Headers["ce_id"]; id != "" {
+		return "event:" + msg.Headers["ce_source"] + ":" + id
+	}
+	if isStructuredCloudEvent(msg) {
+		event := struct {
+			Id     string `json:"id"`
+			Source string `json:"source"`
+		}{}
+		if json.Unmarshal(msg.Value, &event) == nil && event.Id != "" {
+			return "event:" + event.Source + ":" + event.Id
+		}
+	}
+	return "offset:" + msg.Topic + ":" + strconv.Itoa(msg.Partition) + ":" + strconv.FormatInt(msg.Offset, 10)
+}
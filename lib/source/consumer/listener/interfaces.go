@@ -17,12 +17,13 @@
 package listener
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 )
 
 type Controller interface {
-	LogHub(log model.HubLog) error
-	LogDevice(log model.DeviceLog) error
-	UpdateDevice(command model.DeviceCommand) error
-	UpdateHub(command model.HubCommand) error
+	LogHub(ctx context.Context, log model.HubLog) error
+	LogDevice(ctx context.Context, log model.DeviceLog) error
+	UpdateDevice(ctx context.Context, command model.DeviceCommand) error
+	UpdateHub(ctx context.Context, command model.HubCommand) error
 }
@@ -17,9 +17,19 @@
 package listener
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 )
 
-type Listener func(msg []byte) (err error)
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Value     []byte
+	Headers   map[string]string
+}
 
-var Factories = []func(config config.Config, control Controller) (topic string, listener Listener, err error){}
+type Listener func(ctx context.Context, msg Message) (err error)
+
+// Factory creates the Listener for messages of topic
+type Factory func(config config.Config, topic string, control Controller) (listener Listener, err error)
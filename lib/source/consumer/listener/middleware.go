@@ -0,0 +1,63 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+)
+
+// Middleware decorates the Controller called by the listeners, e.g. to drop, rewrite or enrich logs and commands.
+// a Middleware that returns nil without calling next drops the message; the message is still committed.
+type Middleware func(next Controller) Controller
+
+// MiddlewareFactories maps config.MiddlewareConfig.Type to Middleware constructors.
+// additional middlewares may be registered before the consumers are started.
+var MiddlewareFactories = map[string]func(config config.Config, options json.RawMessage) (Middleware, error){}
+
+// Chain wraps control with middlewares; the first middleware is the first to handle a message.
+func Chain(control Controller, middlewares ...Middleware) Controller {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		control = middlewares[i](control)
+	}
+	return control
+}
+
+// ChainFromConfig wraps control with the middlewares configured in config.Middlewares.
+func ChainFromConfig(config config.Config, control Controller) (Controller, error) {
+	middlewares := []Middleware{}
+	for _, middlewareConfig := range config.Middlewares {
+		factory, ok := MiddlewareFactories[middlewareConfig.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware type %v", middlewareConfig.Type)
+		}
+		middleware, err := factory(config, middlewareConfig.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v middleware options: %w", middlewareConfig.Type, err)
+		}
+		middlewares = append(middlewares, middleware)
+	}
+	return Chain(control, middlewares...), nil
+}
+
+func parseMiddlewareOptions(options json.RawMessage, result interface{}) error {
+	if len(options) == 0 {
+		return nil
+	}
+	return json.Unmarshal(options, result)
+}
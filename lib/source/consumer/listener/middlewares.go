@@ -0,0 +1,310 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+const (
+	AllowMiddleware       = "allow"
+	DenyMiddleware        = "deny"
+	RewriteIdsMiddleware  = "rewrite_ids"
+	DebugSampleMiddleware = "debug_sample"
+	EnrichMiddleware      = "enrich"
+)
+
+func init() {
+	MiddlewareFactories[AllowMiddleware] = func(config config.Config, options json.RawMessage) (Middleware, error) {
+		return newMatchMiddleware(options, true)
+	}
+	MiddlewareFactories[DenyMiddleware] = func(config config.Config, options json.RawMessage) (Middleware, error) {
+		return newMatchMiddleware(options, false)
+	}
+	MiddlewareFactories[RewriteIdsMiddleware] = newRewriteIdsMiddleware
+	MiddlewareFactories[DebugSampleMiddleware] = newDebugSampleMiddleware
+	MiddlewareFactories[EnrichMiddleware] = newEnrichMiddleware
+}
+
+// IdOwnerMatch matches messages by id (exact or prefix) and owner.
+// owners can only be matched for messages containing an owner (device logs and commands).
+type IdOwnerMatch struct {
+	Ids        []string `json:"ids,omitempty"`
+	IdPrefixes []string `json:"id_prefixes,omitempty"`
+	Owners     []string `json:"owners,omitempty"`
+}
+
+func (this IdOwnerMatch) matchesId(id string) bool {
+	return slices.Contains(this.Ids, id) || slices.ContainsFunc(this.IdPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(id, prefix)
+	})
+}
+
+func (this IdOwnerMatch) hasIdRules() bool {
+	return len(this.Ids) > 0 || len(this.IdPrefixes) > 0
+}
+
+// allows returns true if the message should be passed to the next Controller.
+// allow-lists drop messages that do not match any set list; deny-lists drop messages that match any list.
+func (this IdOwnerMatch) allows(id string, owner string, allowList bool) bool {
+	if allowList {
+		if this.hasIdRules() && !this.matchesId(id) {
+			return false
+		}
+		if len(this.Owners) > 0 && owner != "" && !slices.Contains(this.Owners, owner) {
+			return false
+		}
+		return true
+	}
+	if this.matchesId(id) {
+		return false
+	}
+	if owner != "" && slices.Contains(this.Owners, owner) {
+		return false
+	}
+	return true
+}
+
+func newMatchMiddleware(options json.RawMessage, allowList bool) (Middleware, error) {
+	match := IdOwnerMatch{}
+	err := parseMiddlewareOptions(options, &match)
+	if err != nil {
+		return nil, err
+	}
+	if !match.hasIdRules() && len(match.Owners) == 0 {
+		return nil, errors.New("expect at least one of ids, id_prefixes or owners")
+	}
+	return func(next Controller) Controller {
+		return &matchMiddleware{Controller: next, match: match, allowList: allowList}
+	}, nil
+}
+
+type matchMiddleware struct {
+	Controller
+	match     IdOwnerMatch
+	allowList bool
+}
+
+func (this *matchMiddleware) LogHub(ctx context.Context, log model.HubLog) error {
+	if !this.match.allows(log.Id, log.HubOwner, this.allowList) {
+		return nil
+	}
+	return this.Controller.LogHub(ctx, log)
+}
+
+func (this *matchMiddleware) LogDevice(ctx context.Context, log model.DeviceLog) error {
+	if !this.match.allows(log.Id, log.DeviceOwner, this.allowList) {
+		return nil
+	}
+	return this.Controller.LogDevice(ctx, log)
+}
+
+func (this *matchMiddleware) UpdateDevice(ctx context.Context, command model.DeviceCommand) error {
+	if !this.match.allows(command.Id, command.Owner, this.allowList) {
+		return nil
+	}
+	return this.Controller.UpdateDevice(ctx, command)
+}
+
+func (this *matchMiddleware) UpdateHub(ctx context.Context, command model.HubCommand) error {
+	if !this.match.allows(command.Id, command.Owner, this.allowList) {
+		return nil
+	}
+	return this.Controller.UpdateHub(ctx, command)
+}
+
+// RewriteIdsOptions replaces ids found in Mapping; other ids get TrimPrefix removed and AddPrefix prepended.
+type RewriteIdsOptions struct {
+	Mapping    map[string]string `json:"mapping,omitempty"`
+	TrimPrefix string            `json:"trim_prefix,omitempty"`
+	AddPrefix  string            `json:"add_prefix,omitempty"`
+}
+
+func (this RewriteIdsOptions) rewrite(id string) string {
+	if mapped, ok := this.Mapping[id]; ok {
+		return mapped
+	}
+	return this.AddPrefix + strings.TrimPrefix(id, this.TrimPrefix)
+}
+
+func newRewriteIdsMiddleware(config config.Config, options json.RawMessage) (Middleware, error) {
+	rewrite := RewriteIdsOptions{}
+	err := parseMiddlewareOptions(options, &rewrite)
+	if err != nil {
+		return nil, err
+	}
+	return func(next Controller) Controller {
+		return &rewriteIdsMiddleware{Controller: next, options: rewrite}
+	}, nil
+}
+
+type rewriteIdsMiddleware struct {
+	Controller
+	options RewriteIdsOptions
+}
+
+func (this *rewriteIdsMiddleware) LogHub(ctx context.Context, log model.HubLog) error {
+	log.Id = this.options.rewrite(log.Id)
+	return this.Controller.LogHub(ctx, log)
+}
+
+func (this *rewriteIdsMiddleware) LogDevice(ctx context.Context, log model.DeviceLog) error {
+	log.Id = this.options.rewrite(log.Id)
+	return this.Controller.LogDevice(ctx, log)
+}
+
+func (this *rewriteIdsMiddleware) UpdateDevice(ctx context.Context, command model.DeviceCommand) error {
+	command.Id = this.options.rewrite(command.Id)
+	return this.Controller.UpdateDevice(ctx, command)
+}
+
+func (this *rewriteIdsMiddleware) UpdateHub(ctx context.Context, command model.HubCommand) error {
+	command.Id = this.options.rewrite(command.Id)
+	return this.Controller.UpdateHub(ctx, command)
+}
+
+// DebugSampleOptions.Rate is the fraction (0 < rate <= 1) of messages logged while config.Debug is set.
+type DebugSampleOptions struct {
+	Rate float64 `json:"rate"`
+}
+
+func newDebugSampleMiddleware(config config.Config, options json.RawMessage) (Middleware, error) {
+	sample := DebugSampleOptions{Rate: 1}
+	err := parseMiddlewareOptions(options, &sample)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Rate <= 0 || sample.Rate > 1 {
+		return nil, errors.New("rate must be in (0, 1]")
+	}
+	return func(next Controller) Controller {
+		if !config.Debug {
+			return next
+		}
+		return &debugSampleMiddleware{Controller: next, rate: sample.Rate}
+	}, nil
+}
+
+type debugSampleMiddleware struct {
+	Controller
+	rate float64
+}
+
+func (this *debugSampleMiddleware) sample(kind string, value interface{}) {
+	if rand.Float64() < this.rate {
+		log.Printf("DEBUG: sampled %v %#v\n", kind, value)
+	}
+}
+
+func (this *debugSampleMiddleware) LogHub(ctx context.Context, log model.HubLog) error {
+	this.sample("hub log", log)
+	return this.Controller.LogHub(ctx, log)
+}
+
+func (this *debugSampleMiddleware) LogDevice(ctx context.Context, log model.DeviceLog) error {
+	this.sample("device log", log)
+	return this.Controller.LogDevice(ctx, log)
+}
+
+func (this *debugSampleMiddleware) UpdateDevice(ctx context.Context, command model.DeviceCommand) error {
+	this.sample("device command", command)
+	return this.Controller.UpdateDevice(ctx, command)
+}
+
+func (this *debugSampleMiddleware) UpdateHub(ctx context.Context, command model.HubCommand) error {
+	this.sample("hub command", command)
+	return this.Controller.UpdateHub(ctx, command)
+}
+
+// EnrichOptions adds Metadata to device and hub logs matching Match (all logs if Match is empty).
+// MonitorConnectionState is used for device logs without own monitor_connection_state, DutyCycle for device logs without own duty_cycle
+// and MonitorFlapping for device logs without own monitor_flapping.
+// existing metadata keys are not overwritten.
+type EnrichOptions struct {
+	Match                  IdOwnerMatch      `json:"match"`
+	Metadata               map[string]string `json:"metadata,omitempty"`
+	MonitorConnectionState string            `json:"monitor_connection_state,omitempty"`
+	DutyCycle              string            `json:"duty_cycle,omitempty"`
+	MonitorFlapping        string            `json:"monitor_flapping,omitempty"`
+}
+
+func (this EnrichOptions) matches(id string, owner string) bool {
+	if !this.Match.hasIdRules() && len(this.Match.Owners) == 0 {
+		return true
+	}
+	return this.Match.matchesId(id) || (owner != "" && slices.Contains(this.Match.Owners, owner))
+}
+
+func (this EnrichOptions) enrich(metadata map[string]string) map[string]string {
+	if len(this.Metadata) == 0 {
+		return metadata
+	}
+	result := maps.Clone(this.Metadata)
+	maps.Copy(result, metadata)
+	return result
+}
+
+func newEnrichMiddleware(config config.Config, options json.RawMessage) (Middleware, error) {
+	enrich := EnrichOptions{}
+	err := parseMiddlewareOptions(options, &enrich)
+	if err != nil {
+		return nil, err
+	}
+	return func(next Controller) Controller {
+		return &enrichMiddleware{Controller: next, options: enrich}
+	}, nil
+}
+
+type enrichMiddleware struct {
+	Controller
+	options EnrichOptions
+}
+
+func (this *enrichMiddleware) LogHub(ctx context.Context, log model.HubLog) error {
+	if this.options.matches(log.Id, log.HubOwner) {
+		log.Metadata = this.options.enrich(log.Metadata)
+		if log.MonitorConnectionState == "" {
+			log.MonitorConnectionState = this.options.MonitorConnectionState
+		}
+	}
+	return this.Controller.LogHub(ctx, log)
+}
+
+func (this *enrichMiddleware) LogDevice(ctx context.Context, log model.DeviceLog) error {
+	if this.options.matches(log.Id, log.DeviceOwner) {
+		log.Metadata = this.options.enrich(log.Metadata)
+		if log.MonitorConnectionState == "" {
+			log.MonitorConnectionState = this.options.MonitorConnectionState
+		}
+		if log.DutyCycle == "" {
+			log.DutyCycle = this.options.DutyCycle
+		}
+		if log.MonitorFlapping == "" {
+			log.MonitorFlapping = this.options.MonitorFlapping
+		}
+	}
+	return this.Controller.LogDevice(ctx, log)
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strconv"
+	"sync"
+)
+
+func init() {
+	PayloadDecoderFactories[EncodingProtobuf] = NewProtobufDecoder
+}
+
+// ProtobufDecoder decodes messages in the Confluent protobuf wire format.
+// the schema is resolved by the schema id of the message and the decoded message is mapped to result
+// by its proto field names (e.g. device_owner).
+type ProtobufDecoder struct {
+	registry *schemaregistry.Client
+	mux      sync.Mutex
+	files    map[int]protoreflect.FileDescriptor
+}
+
+func NewProtobufDecoder(config config.Config) (PayloadDecoder, error) {
+	registry, err := getSchemaRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ProtobufDecoder{registry: registry, files: map[int]protoreflect.FileDescriptor{}}, nil
+}
+
+func (this *ProtobufDecoder) Decode(msg []byte, result interface{}) error {
+	schemaId, payload, err := schemaregistry.SplitWireFormat(msg)
+	if err != nil {
+		return err
+	}
+	indexes, payload, err := readMessageIndexes(payload)
+	if err != nil {
+		return err
+	}
+	file, err := this.getFileDescriptor(schemaId)
+	if err != nil {
+		return err
+	}
+	descriptor, err := getMessageDescriptor(file, indexes)
+	if err != nil {
+		return err
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	err = proto.Unmarshal(payload, message)
+	if err != nil {
+		return err
+	}
+	temp, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(temp, result)
+}
+
+// readMessageIndexes reads the zigzag encoded message indexes which locate the message type in the schema.
+// a single 0 byte is the short form of the first message type.
+func readMessageIndexes(payload []byte) (indexes []int, rest []byte, err error) {
+	count, n := protowire.ConsumeVarint(payload)
+	if n < 0 {
+		return nil, nil, errors.New("invalid protobuf message indexes")
+	}
+	payload = payload[n:]
+	length := protowire.DecodeZigZag(count)
+	if length == 0 {
+		return []int{0}, payload, nil
+	}
+	for i := int64(0); i < length; i++ {
+		index, n := protowire.ConsumeVarint(payload)
+		if n < 0 {
+			return nil, nil, errors.New("invalid protobuf message indexes")
+		}
+		payload = payload[n:]
+		indexes = append(indexes, int(protowire.DecodeZigZag(index)))
+	}
+	return indexes, payload, nil
+}
+
+func getMessageDescriptor(file protoreflect.FileDescriptor, indexes []int) (result protoreflect.MessageDescriptor, err error) {
+	messages := file.Messages()
+	for _, index := range indexes {
+		if index < 0 || index >= messages.Len() {
+			return nil, fmt.Errorf("unknown message index %v in %v", index, file.Path())
+		}
+		result = messages.Get(index)
+		messages = result.Messages()
+	}
+	return result, nil
+}
+
+func (this *ProtobufDecoder) getFileDescriptor(schemaId int) (result protoreflect.FileDescriptor, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result, ok := this.files[schemaId]
+	if ok {
+		return result, nil
+	}
+	schema, err := this.registry.GetSchemaById(schemaId)
+	if err != nil {
+		return nil, err
+	}
+	if schema.SchemaType != schemaregistry.SchemaTypeProtobuf {
+		return nil, fmt.Errorf("schema %v is of type %v, expected %v", schemaId, schema.SchemaType, schemaregistry.SchemaTypeProtobuf)
+	}
+	name := strconv.Itoa(schemaId) + ".proto"
+	sources := map[string]string{name: schema.Schema}
+	err = this.collectReferences(schema.References, sources)
+	if err != nil {
+		return nil, err
+	}
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+	result = files[0]
+	this.files[schemaId] = result
+	return result, nil
+}
+
+func (this *ProtobufDecoder) collectReferences(references []schemaregistry.Reference, sources map[string]string) error {
+	for _, ref := range references {
+		if _, ok := sources[ref.Name]; ok {
+			continue
+		}
+		schema, err := this.registry.GetSchemaByVersion(ref.Subject, ref.Version)
+		if err != nil {
+			return err
+		}
+		sources[ref.Name] = schema.Schema
+		err = this.collectReferences(schema.References, sources)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
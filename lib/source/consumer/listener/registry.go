@@ -0,0 +1,155 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"slices"
+	"sync"
+)
+
+const (
+	DeviceLogListenerName = "device_log"
+	HubLogListenerName    = "hub_log"
+	DevicesListenerName   = "devices"
+	HubsListenerName      = "hubs"
+)
+
+type Registration struct {
+	Name string
+	// DefaultTopic returns the topic consumed if config.ListenerTopics contains no topic for Name
+	DefaultTopic func(config config.Config) string
+	Factory      Factory
+}
+
+// EnabledListener is a Registration with its resolved topic
+type EnabledListener struct {
+	Name    string
+	Topic   string
+	Factory Factory
+}
+
+// Registry contains the listeners a worker may run.
+// which of them are started, and with which topics, is decided by config.EnabledListeners,
+// config.DisabledListeners and config.ListenerTopics.
+type Registry struct {
+	mux           sync.Mutex
+	registrations []Registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry returns a new Registry containing the device_log, hub_log, devices and hubs listeners.
+// custom listeners may be added with Register.
+func DefaultRegistry() *Registry {
+	result := NewRegistry()
+	for _, registration := range []Registration{
+		{
+			Name:         DeviceLogListenerName,
+			DefaultTopic: func(config config.Config) string { return config.DeviceLogTopic },
+			Factory:      DeviceLogListenerFactory,
+		},
+		{
+			Name:         HubLogListenerName,
+			DefaultTopic: func(config config.Config) string { return config.HubLogTopic },
+			Factory:      HubLogListenerFactory,
+		},
+		{
+			Name:         DevicesListenerName,
+			DefaultTopic: func(config config.Config) string { return config.DeviceTopic },
+			Factory:      DevicesListenerFactory,
+		},
+		{
+			Name:         HubsListenerName,
+			DefaultTopic: func(config config.Config) string { return config.HubTopic },
+			Factory:      HubsListenerFactory,
+		},
+	} {
+		err := result.Register(registration)
+		if err != nil {
+			panic(err) //builtin registrations are expected to be valid
+		}
+	}
+	return result
+}
+
+func (this *Registry) Register(registration Registration) error {
+	if registration.Name == "" {
+		return errors.New("missing listener name")
+	}
+	if registration.Factory == nil {
+		return fmt.Errorf("missing factory for listener %v", registration.Name)
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if slices.ContainsFunc(this.registrations, func(e Registration) bool { return e.Name == registration.Name }) {
+		return fmt.Errorf("listener %v is already registered", registration.Name)
+	}
+	this.registrations = append(this.registrations, registration)
+	return nil
+}
+
+func (this *Registry) Names() (result []string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, registration := range this.registrations {
+		result = append(result, registration.Name)
+	}
+	return result
+}
+
+// Enabled returns the listeners to start for config in registration order.
+// if config.EnabledListeners is not empty, only the listed listeners are enabled; listeners in config.DisabledListeners are never enabled.
+// unknown names in these lists and in config.ListenerTopics are reported as error.
+func (this *Registry) Enabled(config config.Config) (result []EnabledListener, err error) {
+	names := this.Names()
+	for _, list := range [][]string{config.EnabledListeners, config.DisabledListeners} {
+		for _, name := range list {
+			if !slices.Contains(names, name) {
+				return nil, fmt.Errorf("unknown listener %v (known: %v)", name, names)
+			}
+		}
+	}
+	for name := range config.ListenerTopics {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown listener %v in ListenerTopics (known: %v)", name, names)
+		}
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, registration := range this.registrations {
+		if len(config.EnabledListeners) > 0 && !slices.Contains(config.EnabledListeners, registration.Name) {
+			continue
+		}
+		if slices.Contains(config.DisabledListeners, registration.Name) {
+			continue
+		}
+		topic := config.ListenerTopics[registration.Name]
+		if topic == "" && registration.DefaultTopic != nil {
+			topic = registration.DefaultTopic(config)
+		}
+		if topic == "" {
+			return nil, fmt.Errorf("missing topic for listener %v", registration.Name)
+		}
+		result = append(result, EnabledListener{Name: registration.Name, Topic: topic, Factory: registration.Factory})
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJson     = "JSON"
+)
+
+type Schema struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Client resolves schemas of a Confluent compatible schema registry.
+// schemas are immutable per id and version, which is why every resolved schema is cached for the lifetime of the client.
+type Client struct {
+	url        string
+	tokens     *auth.Tokens
+	httpClient *http.Client
+	mux        sync.Mutex
+	byId       map[int]Schema
+	byVersion  map[string]Schema
+}
+
+// New returns a client for the registry at url; requests are authenticated with tokens, if not nil
+func New(url string, tokens *auth.Tokens) *Client {
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		tokens:     tokens,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		byId:       map[int]Schema{},
+		byVersion:  map[string]Schema{},
+	}
+}
+
+func (this *Client) GetSchemaById(id int) (result Schema, err error) {
+	this.mux.Lock()
+	result, ok := this.byId[id]
+	this.mux.Unlock()
+	if ok {
+		return result, nil
+	}
+	err = this.get("/schemas/ids/"+strconv.Itoa(id), &result)
+	if err != nil {
+		return result, err
+	}
+	if result.SchemaType == "" {
+		result.SchemaType = SchemaTypeAvro
+	}
+	this.mux.Lock()
+	this.byId[id] = result
+	this.mux.Unlock()
+	return result, nil
+}
+
+func (this *Client) GetSchemaByVersion(subject string, version int) (result Schema, err error) {
+	key := subject + "/" + strconv.Itoa(version)
+	this.mux.Lock()
+	result, ok := this.byVersion[key]
+	this.mux.Unlock()
+	if ok {
+		return result, nil
+	}
+	err = this.get("/subjects/"+url.PathEscape(subject)+"/versions/"+strconv.Itoa(version), &result)
+	if err != nil {
+		return result, err
+	}
+	if result.SchemaType == "" {
+		result.SchemaType = SchemaTypeAvro
+	}
+	this.mux.Lock()
+	this.byVersion[key] = result
+	this.mux.Unlock()
+	return result, nil
+}
+
+func (this *Client) get(path string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, this.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if this.tokens != nil {
+		token, err := this.tokens.Authorization("")
+		if err != nil {
+			return fmt.Errorf("unable to get schema registry token: %w", err)
+		}
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respMsg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected response status from schema registry %v %v", resp.Status, string(respMsg))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+var ErrMissingMagicByte = errors.New("message is not in the schema registry wire format (missing magic byte)")
+
+// SplitWireFormat returns the schema id and the remaining payload of a message in the Confluent wire format
+// (magic byte 0 followed by a 4 byte big endian schema id).
+func SplitWireFormat(msg []byte) (schemaId int, payload []byte, err error) {
+	if len(msg) < 5 || msg[0] != 0 {
+		return 0, nil, ErrMissingMagicByte
+	}
+	return int(binary.BigEndian.Uint32(msg[1:5])), msg[5:], nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"time"
+)
+
+const ServiceName = "connection-log-worker"
+
+// Start registers the W3C trace context propagator and, if config.OtlpTraceUrl is set,
+// a tracer provider exporting spans via OTLP/HTTP. the provider is flushed and stopped when ctx is done.
+func Start(ctx context.Context, config config.Config) error {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if config.OtlpTraceUrl == "" || config.OtlpTraceUrl == "-" {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.OtlpTraceUrl))
+	if err != nil {
+		return err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Second)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := provider.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Println("ERROR: unable to shutdown tracer provider", err)
+		}
+	}()
+	return nil
+}
+
+// Extract returns a context containing the remote span context found in headers (e.g. traceparent).
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// Inject returns the trace context of ctx as map, e.g. to be stored with deferred work and restored by Extract
+func Inject(ctx context.Context) map[string]string {
+	result := map[string]string{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(result))
+	return result
+}
+
+// End records err (if not nil) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
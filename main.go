@@ -22,10 +22,10 @@ import (
 	"github.com/SENERGY-Platform/api-docs-provider/lib/client"
 	"github.com/SENERGY-Platform/connection-log-worker/docs"
 	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -63,6 +63,7 @@ func main() {
 }
 
 func PublishAsyncApiDoc(conf config.Config) error {
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
-	return client.New(http.DefaultClient, conf.ApiDocsProviderBaseUrl).AsyncapiPutDoc(ctx, "github_com_SENERGY-Platform_connection-log-worker", docs.AsyncApiDoc)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return client.New(auth.New(conf).Client(), conf.ApiDocsProviderBaseUrl).AsyncapiPutDoc(ctx, "github_com_SENERGY-Platform_connection-log-worker", docs.AsyncApiDoc)
 }
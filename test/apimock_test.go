@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"slices"
+	"strings"
+)
+
+type ApiControllerMock struct {
+	Windows     map[string]model.MaintenanceWindow
+	OptOuts     []model.NotificationOptOut
+	Preferences map[string]model.NotificationPreferences
+	Audit       []model.NotificationAuditEntry
+}
+
+func NewApiControllerMock() *ApiControllerMock {
+	return &ApiControllerMock{Windows: map[string]model.MaintenanceWindow{}, Preferences: map[string]model.NotificationPreferences{}}
+}
+
+func (this *ApiControllerMock) ListMaintenanceWindows(_ context.Context, scope string, targetId string) (result []model.MaintenanceWindow, err error) {
+	result = []model.MaintenanceWindow{}
+	for _, window := range this.Windows {
+		if (scope == "" || window.Scope == scope) && (targetId == "" || window.TargetId == targetId) {
+			result = append(result, window)
+		}
+	}
+	slices.SortFunc(result, func(a, b model.MaintenanceWindow) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return result, nil
+}
+
+func (this *ApiControllerMock) GetMaintenanceWindow(_ context.Context, id string) (model.MaintenanceWindow, bool, error) {
+	window, ok := this.Windows[id]
+	return window, ok, nil
+}
+
+func (this *ApiControllerMock) SetMaintenanceWindow(_ context.Context, window model.MaintenanceWindow) error {
+	this.Windows[window.Id] = window
+	return nil
+}
+
+func (this *ApiControllerMock) DeleteMaintenanceWindow(_ context.Context, id string) error {
+	delete(this.Windows, id)
+	return nil
+}
+
+func (this *ApiControllerMock) ListNotificationOptOuts(_ context.Context, userId string) (result []model.NotificationOptOut, err error) {
+	result = []model.NotificationOptOut{}
+	for _, optOut := range this.OptOuts {
+		if optOut.UserId == userId {
+			result = append(result, optOut)
+		}
+	}
+	return result, nil
+}
+
+func (this *ApiControllerMock) SetNotificationOptOut(ctx context.Context, optOut model.NotificationOptOut) error {
+	_ = this.DeleteNotificationOptOut(ctx, optOut.UserId, optOut.ResourceId)
+	this.OptOuts = append(this.OptOuts, optOut)
+	return nil
+}
+
+func (this *ApiControllerMock) DeleteNotificationOptOut(_ context.Context, userId string, resourceId string) error {
+	this.OptOuts = slices.DeleteFunc(this.OptOuts, func(optOut model.NotificationOptOut) bool {
+		return optOut.UserId == userId && optOut.ResourceId == resourceId
+	})
+	return nil
+}
+
+func (this *ApiControllerMock) GetNotificationPreferences(_ context.Context, userId string) (model.NotificationPreferences, bool, error) {
+	preferences, ok := this.Preferences[userId]
+	if !ok {
+		return model.NotificationPreferences{UserId: userId}, false, nil
+	}
+	return preferences, true, nil
+}
+
+func (this *ApiControllerMock) SetNotificationPreferences(_ context.Context, preferences model.NotificationPreferences) error {
+	this.Preferences[preferences.UserId] = preferences
+	return nil
+}
+
+func (this *ApiControllerMock) DeleteNotificationPreferences(_ context.Context, userId string) error {
+	delete(this.Preferences, userId)
+	return nil
+}
+
+func (this *ApiControllerMock) ListNotificationAudit(_ context.Context, query model.NotificationAuditQuery) (result []model.NotificationAuditEntry, err error) {
+	result = []model.NotificationAuditEntry{}
+	for _, entry := range this.Audit {
+		if (query.DeviceId == "" || entry.DeviceId == query.DeviceId) &&
+			(query.Owner == "" || entry.Owner == query.Owner) &&
+			(query.Decision == "" || entry.Decision == query.Decision) &&
+			(query.From.IsZero() || !entry.Time.Before(query.From)) &&
+			(query.To.IsZero() || entry.Time.Before(query.To)) {
+			result = append(result, entry)
+		}
+	}
+	result = result[min(query.Offset, len(result)):]
+	return result[:min(query.Limit, len(result))], nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/api"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationAuditApi(t *testing.T) {
+	mock := NewApiControllerMock()
+	now := time.Now().Truncate(time.Second).UTC()
+	mock.Audit = []model.NotificationAuditEntry{
+		{Id: "1", DeviceId: "d1", Owner: "owner1", Decision: model.NotificationDecisionSent, Time: now},
+		{Id: "2", DeviceId: "d1", Owner: "owner1", Decision: model.NotificationDecisionSuppressed, Reason: "maintenance window", Time: now.Add(-time.Hour)},
+		{Id: "3", DeviceId: "d2", Owner: "owner2", Decision: model.NotificationDecisionSent, Time: now.Add(-2 * time.Hour)},
+	}
+	s := httptest.NewServer(api.GetRouter(config.Config{NotificationAuditCollection: "audit"}, mock))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/admin/notifications/audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error(resp.StatusCode)
+	}
+
+	ids := func(list []model.NotificationAuditEntry) (result []string) {
+		result = []string{}
+		for _, entry := range list {
+			result = append(result, entry.Id)
+		}
+		return result
+	}
+	cases := []struct {
+		query    string
+		status   int
+		expected []string
+	}{
+		{query: "", status: http.StatusOK, expected: []string{"1", "2", "3"}},
+		{query: "?device_id=d1", status: http.StatusOK, expected: []string{"1", "2"}},
+		{query: "?owner=owner2", status: http.StatusOK, expected: []string{"3"}},
+		{query: "?decision=sent", status: http.StatusOK, expected: []string{"1", "3"}},
+		{query: "?from=" + now.Add(-90*time.Minute).Format(time.RFC3339), status: http.StatusOK, expected: []string{"1", "2"}},
+		{query: "?to=" + now.Format(time.RFC3339), status: http.StatusOK, expected: []string{"2", "3"}},
+		{query: "?limit=1&offset=1", status: http.StatusOK, expected: []string{"2"}},
+		{query: "?from=yesterday", status: http.StatusBadRequest},
+		{query: "?limit=0", status: http.StatusBadRequest},
+		{query: "?offset=-1", status: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		list := []model.NotificationAuditEntry{}
+		status, err := helper.AdminRequest("GET", s.URL+"/admin/notifications/audit"+c.query, nil, &list)
+		if err != nil || status != c.status {
+			t.Error(c.query, status, err)
+			continue
+		}
+		if status == http.StatusOK && !reflect.DeepEqual(ids(list), c.expected) {
+			t.Error(c.query, ids(list))
+		}
+	}
+}
+
+func TestDeliveryOutcomeAuditDecision(t *testing.T) {
+	cases := []struct {
+		outcome  controller.DeliveryOutcome
+		decision string
+		reason   string
+	}{
+		{outcome: controller.DeliverySent, decision: model.NotificationDecisionSent, reason: "offline duration exceeded"},
+		{outcome: controller.DeliveryQueued, decision: model.NotificationDecisionDeferred, reason: "queued in outbox"},
+		{outcome: controller.DeliveryDelayed, decision: model.NotificationDecisionDeferred, reason: "delayed by quiet hours"},
+		{outcome: controller.DeliveryDigest, decision: model.NotificationDecisionDeferred, reason: "collected in digest"},
+		{outcome: controller.DeliveryQuietHoursDropped, decision: model.NotificationDecisionSuppressed, reason: "dropped during quiet hours"},
+		{outcome: controller.DeliveryMuted, decision: model.NotificationDecisionSuppressed, reason: "muted by user preferences"},
+		{outcome: controller.DeliveryNoRecipients, decision: model.NotificationDecisionSuppressed, reason: "no recipients"},
+	}
+	for _, c := range cases {
+		decision, reason := c.outcome.AuditDecision("offline duration exceeded")
+		if decision != c.decision || reason != c.reason {
+			t.Error(c.outcome, decision, reason)
+		}
+	}
+}
+
+func TestNotificationAudit(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.NotifyOnReconnect = true
+	defaultConfig.NotificationAuditCollection = "notification_audit"
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func(id string, owner string, connected bool) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              connected,
+			Time:                   time.Now(),
+			MonitorConnectionState: "1s",
+			DeviceOwner:            owner,
+			DeviceName:             "device " + id,
+		})
+	}
+
+	//d3 is muted by its owner
+	adminId := "dd69ea0d-f553-4336-80f3-7f4567f85c7b" //sub of helper.AdminJwt
+	status, err := helper.AdminRequest("PUT", "http://localhost:"+conf.ApiPort+"/notifications/preferences", model.NotificationPreferences{
+		MutedDevices: []string{"d3"},
+	}, nil)
+	if err != nil || status != http.StatusOK {
+		t.Fatal(status, err)
+	}
+
+	send("d1", "owner1", false)
+	send("d2", "", false)
+	send("d3", adminId, false)
+	time.Sleep(2 * time.Second)
+	send("d1", "owner1", false)
+	send("d2", "", false)
+	send("d3", adminId, false)
+	time.Sleep(time.Second)
+	send("d1", "owner1", false)
+	time.Sleep(time.Second)
+	send("d1", "owner1", true)
+	time.Sleep(2 * time.Second)
+
+	session, err := mgo.Dial(conf.MongoUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	entries := []model.NotificationAuditEntry{}
+	err = session.DB(conf.MongoTable).C(conf.NotificationAuditCollection).Find(bson.M{}).Sort("time").All(&entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := map[string][]string{}
+	for _, entry := range entries {
+		actual[entry.DeviceId] = append(actual[entry.DeviceId], entry.Decision+": "+entry.Reason)
+	}
+	expected := map[string][]string{
+		"d1": {"pending: device went offline", "sent: offline duration exceeded", "skipped: already notified", "sent: device reconnected"},
+		"d2": {"pending: device went offline", "missing_owner: device has no owner"},
+		"d3": {"pending: device went offline", "suppressed: muted by user preferences"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, actual)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/auth"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestClientCredentials(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cached := server.NewTokenIssuer(ctx, wg, "worker", "secret", 3600)
+	expiring := server.NewTokenIssuer(ctx, wg, "worker", "secret", 1) //expires within the refresh margin of the oauth2 package
+
+	t.Run("disabled", func(t *testing.T) {
+		tokens := auth.New(config.Config{AuthTokenUrl: "-"})
+		if tokens != nil {
+			t.Error("expected nil tokens")
+		}
+		token, err := tokens.Authorization("admin")
+		if err != nil || token != "admin" {
+			t.Error(token, err)
+		}
+		if tokens.Client() != http.DefaultClient {
+			t.Error("expected default client")
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		conf := config.Config{AuthTokenUrl: cached.TokenUrl, AuthClientId: "worker", AuthClientSecret: "secret"}
+		tokens := auth.New(conf)
+		if auth.New(conf) != tokens {
+			t.Error("expected shared tokens")
+		}
+		for i := 0; i < 3; i++ {
+			token, err := tokens.Authorization("")
+			if err != nil || token != "Bearer token-1" {
+				t.Error(token, err)
+			}
+		}
+		if cached.Issued() != 1 {
+			t.Error(cached.Issued())
+		}
+	})
+
+	t.Run("refreshed", func(t *testing.T) {
+		tokens := auth.New(config.Config{AuthTokenUrl: expiring.TokenUrl, AuthClientId: "worker", AuthClientSecret: "secret"})
+		for i := 0; i < 3; i++ {
+			_, err := tokens.Authorization("")
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		if expiring.Issued() != 3 {
+			t.Error(expiring.Issued())
+		}
+	})
+
+	t.Run("invalid secret", func(t *testing.T) {
+		tokens := auth.New(config.Config{AuthTokenUrl: cached.TokenUrl, AuthClientId: "worker", AuthClientSecret: "wrong"})
+		_, err := tokens.Authorization("")
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("notifier", func(t *testing.T) {
+		authorized := 0
+		service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cached.Valid(r.Header.Get("Authorization")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			authorized++
+		}))
+		defer service.Close()
+		webhookAuthorization := "unset"
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			webhookAuthorization = r.Header.Get("Authorization")
+		}))
+		defer webhook.Close()
+		channels, err := notifier.New(config.Config{
+			NotificationUrl:  service.URL,
+			AuthTokenUrl:     cached.TokenUrl,
+			AuthClientId:     "worker",
+			AuthClientSecret: "secret",
+			NotificationChannels: map[string]config.NotificationChannelConfig{
+				"hook": {Type: notifier.WebhookType, Options: []byte(`{"url":"` + webhook.URL + `"}`)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = channels.Notify(ctx, "notifier,notifier,hook", notifier.Notification{UserId: "owner1", Title: "title", Message: "message"})
+		if err != nil {
+			t.Error(err)
+		}
+		if authorized != 2 {
+			t.Error(authorized)
+		}
+		if webhookAuthorization != "" {
+			t.Error("webhooks should not receive platform tokens", webhookAuthorization)
+		}
+		if cached.Issued() != 1 {
+			t.Error(cached.Issued())
+		}
+	})
+
+	t.Run("schema registry", func(t *testing.T) {
+		registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cached.Valid(r.Header.Get("Authorization")) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"schema":"{\"type\":\"string\"}"}`))
+		}))
+		defer registry.Close()
+		client := schemaregistry.New(registry.URL, auth.New(config.Config{AuthTokenUrl: cached.TokenUrl, AuthClientId: "worker", AuthClientSecret: "secret"}))
+		schema, err := client.GetSchemaById(1)
+		if err != nil || schema.SchemaType != schemaregistry.SchemaTypeAvro {
+			t.Error(schema, err)
+		}
+		_, err = schemaregistry.New(registry.URL, nil).GetSchemaById(1)
+		if err == nil {
+			t.Error("expected error without token")
+		}
+	})
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ControllerMock struct {
+	HubLogs        []model.HubLog
+	DeviceLogs     []model.DeviceLog
+	DeviceCommands []model.DeviceCommand
+	HubCommands    []model.HubCommand
+}
+
+func (this *ControllerMock) LogHub(ctx context.Context, log model.HubLog) error {
+	this.HubLogs = append(this.HubLogs, log)
+	return nil
+}
+
+func (this *ControllerMock) LogDevice(ctx context.Context, log model.DeviceLog) error {
+	this.DeviceLogs = append(this.DeviceLogs, log)
+	return nil
+}
+
+func (this *ControllerMock) UpdateDevice(ctx context.Context, command model.DeviceCommand) error {
+	this.DeviceCommands = append(this.DeviceCommands, command)
+	return nil
+}
+
+func (this *ControllerMock) UpdateHub(ctx context.Context, command model.HubCommand) error {
+	this.HubCommands = append(this.HubCommands, command)
+	return nil
+}
+
+func TestCloudEvents(t *testing.T) {
+	conf, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	eventTime, _ := time.Parse(time.RFC3339, "2025-01-02T03:04:05Z")
+	payloadTime, _ := time.Parse(time.RFC3339, "2024-01-02T03:04:05Z")
+
+	t.Run("raw json", func(t *testing.T) {
+		control := &ControllerMock{}
+		handler, err := listener.DeviceLogListenerFactory(conf, conf.DeviceLogTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.DeviceLogTopic,
+			Value: []byte(`{"id":"d1","connected":true,"time":"2024-01-02T03:04:05Z"}`),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.DeviceLog{{Id: "d1", Connected: true, Time: payloadTime}}
+		if !reflect.DeepEqual(control.DeviceLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceLogs)
+		}
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		control := &ControllerMock{}
+		handler, err := listener.DeviceLogListenerFactory(conf, conf.DeviceLogTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.DeviceLogTopic,
+			Value: []byte(`{"specversion":"1.0","id":"e1","source":"test","type":"device_log","subject":"d1","time":"2025-01-02T03:04:05Z","data":{"connected":true}}`),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.DeviceLogTopic,
+			Value: []byte(`{"specversion":"1.0","id":"e2","source":"test","type":"device_log","subject":"d1","time":"2025-01-02T03:04:05Z","data":{"id":"d2","connected":false,"time":"2024-01-02T03:04:05Z"}}`),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.DeviceLog{
+			{Id: "d1", Connected: true, Time: eventTime},
+			{Id: "d1", Connected: false, Time: eventTime}, //envelope subject and time win over the payload
+		}
+		if !reflect.DeepEqual(control.DeviceLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceLogs)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		control := &ControllerMock{}
+		handler, err := listener.HubLogListenerFactory(conf, conf.HubLogTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.HubLogTopic,
+			Value: []byte(`{"connected":true}`),
+			Headers: map[string]string{
+				"ce_specversion": "1.0",
+				"ce_id":          "e1",
+				"ce_source":      "test",
+				"ce_type":        "hub_log",
+				"ce_subject":     "h1",
+				"ce_time":        "2025-01-02T03:04:05Z",
+				"content-type":   "application/json",
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.HubLogTopic,
+			Value: []byte(`{"id":"h2","connected":false,"time":"2024-01-02T03:04:05Z"}`),
+			Headers: map[string]string{
+				"ce_specversion": "1.0",
+				"ce_id":          "e2",
+				"ce_source":      "test",
+				"ce_type":        "hub_log",
+				"ce_subject":     "h1",
+				"ce_time":        "2025-01-02T03:04:05Z",
+				"content-type":   "application/json",
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.HubLogTopic,
+			Value: []byte(`{"id":"h2","connected":true,"time":"2024-01-02T03:04:05Z"}`),
+			Headers: map[string]string{
+				"ce_specversion": "1.0",
+				"ce_id":          "e3",
+				"ce_source":      "test",
+				"ce_type":        "hub_log",
+				"content-type":   "application/json",
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.HubLog{
+			{Id: "h1", Connected: true, Time: eventTime},
+			{Id: "h1", Connected: false, Time: eventTime},  //envelope subject and time win over the payload
+			{Id: "h2", Connected: true, Time: payloadTime}, //no subject and time in the envelope
+		}
+		if !reflect.DeepEqual(control.HubLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.HubLogs)
+		}
+	})
+
+	t.Run("configured encoding", func(t *testing.T) {
+		c := conf
+		c.TopicEncodings = map[string]string{conf.DeviceTopic: listener.EncodingCloudEvents}
+		control := &ControllerMock{}
+		handler, err := listener.DevicesListenerFactory(c, conf.DeviceTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.DeviceTopic,
+			Value: []byte(`{"command":"DELETE","id":"d1"}`),
+		})
+		if err == nil {
+			t.Error("expected error for raw json on cloudevents topic")
+			return
+		}
+		err = handler(context.Background(), listener.Message{
+			Topic: conf.DeviceTopic,
+			Value: []byte(`{"specversion":"1.0","id":"e1","source":"test","type":"devices","subject":"d1","data":{"command":"DELETE"}}`),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.DeviceCommand{{Command: "DELETE", Id: "d1"}}
+		if !reflect.DeepEqual(control.DeviceCommands, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceCommands)
+		}
+	})
+}
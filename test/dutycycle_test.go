@@ -0,0 +1,188 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseDutyCycle(t *testing.T) {
+	profiles := map[string]config.DutyCycleProfile{
+		"sensor":  {Interval: "1h", Tolerance: "5m"},
+		"invalid": {Interval: "foo"},
+	}
+	cases := []struct {
+		value    string
+		expected controller.DutyCycle
+		ok       bool
+		err      bool
+	}{
+		{value: "1h", expected: controller.DutyCycle{Interval: time.Hour, Tolerance: 6 * time.Minute}, ok: true},
+		{value: "1h, 10m", expected: controller.DutyCycle{Interval: time.Hour, Tolerance: 10 * time.Minute}, ok: true},
+		{value: "1d,0s", expected: controller.DutyCycle{Interval: 24 * time.Hour}, ok: true},
+		{value: "PT1,5H,PT0,5M", expected: controller.DutyCycle{Interval: 90 * time.Minute, Tolerance: 30 * time.Second}, ok: true},
+		{value: "PT30M,PT1M", expected: controller.DutyCycle{Interval: 30 * time.Minute, Tolerance: time.Minute}, ok: true},
+		{value: "sensor", expected: controller.DutyCycle{Interval: time.Hour, Tolerance: 5 * time.Minute}, ok: true},
+		{value: "off", ok: false},
+		{value: "invalid", err: true},
+		{value: "foo", err: true},
+		{value: "0s", err: true},
+		{value: "1h,foo", err: true},
+	}
+	for _, c := range cases {
+		actual, ok, err := controller.ParseDutyCycle(c.value, profiles)
+		if (err != nil) != c.err {
+			t.Error(c.value, err)
+			continue
+		}
+		if ok != c.ok || (ok && actual != c.expected) {
+			t.Error(c.value, ok, actual)
+		}
+	}
+}
+
+func TestDutyCycleNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.DutyCycleCheckInterval = "500ms"
+	defaultConfig.DutyCycleProfiles = map[string]config.DutyCycleProfile{"sensor": {Interval: "1m"}}
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func(id string, connected bool, dutyCycle string) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              connected,
+			Time:                   time.Now(),
+			MonitorConnectionState: "1s",
+			DeviceOwner:            "owner1",
+			DeviceName:             "device " + id,
+			DutyCycle:              dutyCycle,
+		})
+	}
+
+	//missed wakeup
+	send("d1", false, "2s,1s")
+	//reconnects in time
+	send("d2", false, "2s,1s")
+	//long interval
+	send("d3", false, "sensor")
+	time.Sleep(1500 * time.Millisecond)
+	send("d1", false, "2s,1s")
+	send("d2", true, "2s,1s")
+	send("d3", false, "sensor")
+	time.Sleep(3 * time.Second)
+
+	session, err := mgo.Dial(conf.MongoUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	states := []controller.DeviceState{}
+	err = session.DB(conf.MongoTable).C(conf.DeviceStateCollection).Find(bson.M{}).Sort("device").All(&states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualStates := map[string]string{}
+	for _, state := range states {
+		actualStates[state.Device] = map[bool]string{true: "online", false: "offline"}[state.Online]
+		if state.Sleeping {
+			actualStates[state.Device] = "sleeping"
+		}
+	}
+	expectedStates := map[string]string{"d1": "offline", "d2": "online", "d3": "sleeping"}
+	if !reflect.DeepEqual(actualStates, expectedStates) {
+		t.Errorf("\ne:%v\na:%v\n", expectedStates, actualStates)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Device Missed Wake-Up\",\"message\":\"sleeping device device d1 (d1) did not reconnect within 3s\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const deviceLogProtoSchema = `
+syntax = "proto3";
+package test;
+
+import "google/protobuf/timestamp.proto";
+
+message DeviceLog {
+  string id = 1;
+  bool connected = 2;
+  google.protobuf.Timestamp time = 3;
+  string monitor_connection_state = 4;
+  string device_owner = 5;
+  string device_name = 6;
+}
+`
+
+const deviceLogAvroSchema = `{
+  "type": "record",
+  "name": "DeviceLog",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "connected", "type": "boolean"},
+    {"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "device_owner", "type": "string"}
+  ]
+}`
+
+func TestBinaryEncodings(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	registry := server.NewSchemaRegistry(ctx, wg)
+	conf.SchemaRegistryUrl = registry.Url
+
+	logTime, _ := time.Parse(time.RFC3339, "2025-01-02T03:04:05Z")
+
+	t.Run("protobuf", func(t *testing.T) {
+		c := conf
+		c.TopicEncodings = map[string]string{conf.DeviceLogTopic: listener.EncodingProtobuf}
+		control := &ControllerMock{}
+		handler, err := listener.DeviceLogListenerFactory(c, conf.DeviceLogTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		schemaId := registry.Register(schemaregistry.SchemaTypeProtobuf, deviceLogProtoSchema)
+
+		timestamp := protowire.AppendTag(nil, 1, protowire.VarintType)
+		timestamp = protowire.AppendVarint(timestamp, uint64(logTime.Unix()))
+		payload := protowire.AppendTag(nil, 1, protowire.BytesType)
+		payload = protowire.AppendString(payload, "d1")
+		payload = protowire.AppendTag(payload, 2, protowire.VarintType)
+		payload = protowire.AppendVarint(payload, 1)
+		payload = protowire.AppendTag(payload, 3, protowire.BytesType)
+		payload = protowire.AppendBytes(payload, timestamp)
+		payload = protowire.AppendTag(payload, 5, protowire.BytesType)
+		payload = protowire.AppendString(payload, "owner")
+
+		msg := append(wireFormatPrefix(schemaId), 0) //message index [0]
+		err = handler(context.Background(), listener.Message{Topic: conf.DeviceLogTopic, Value: append(msg, payload...)})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.DeviceLog{{Id: "d1", Connected: true, Time: logTime, DeviceOwner: "owner"}}
+		if !reflect.DeepEqual(control.DeviceLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceLogs)
+		}
+	})
+
+	t.Run("avro", func(t *testing.T) {
+		c := conf
+		c.TopicEncodings = map[string]string{conf.DeviceLogTopic: listener.EncodingAvro}
+		control := &ControllerMock{}
+		handler, err := listener.DeviceLogListenerFactory(c, conf.DeviceLogTopic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		schemaId := registry.Register(schemaregistry.SchemaTypeAvro, deviceLogAvroSchema)
+		payload, err := avro.Marshal(avro.MustParse(deviceLogAvroSchema), map[string]interface{}{
+			"id":           "d2",
+			"connected":    false,
+			"time":         logTime,
+			"device_owner": "owner",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{Topic: conf.DeviceLogTopic, Value: append(wireFormatPrefix(schemaId), payload...)})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(control.DeviceLogs) != 1 || !control.DeviceLogs[0].Time.Equal(logTime) {
+			t.Errorf("%#v\n", control.DeviceLogs)
+			return
+		}
+		control.DeviceLogs[0].Time = logTime
+		expected := []model.DeviceLog{{Id: "d2", Connected: false, Time: logTime, DeviceOwner: "owner"}}
+		if !reflect.DeepEqual(control.DeviceLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceLogs)
+		}
+	})
+
+	t.Run("wrong schema type", func(t *testing.T) {
+		c := conf
+		c.TopicEncodings = map[string]string{conf.DeviceLogTopic: listener.EncodingAvro}
+		handler, err := listener.DeviceLogListenerFactory(c, conf.DeviceLogTopic, &ControllerMock{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		schemaId := registry.Register(schemaregistry.SchemaTypeProtobuf, deviceLogProtoSchema)
+		err = handler(context.Background(), listener.Message{Topic: conf.DeviceLogTopic, Value: append(wireFormatPrefix(schemaId), 0)})
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func wireFormatPrefix(schemaId int) []byte {
+	result := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(result[1:], uint32(schemaId))
+	return result
+}
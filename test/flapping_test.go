@@ -0,0 +1,186 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseFlappingRule(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected controller.FlappingRule
+		ok       bool
+		err      bool
+	}{
+		{value: "10/1h", expected: controller.FlappingRule{Disconnects: 10, Window: time.Hour}, ok: true},
+		{value: " 3 / 15m ", expected: controller.FlappingRule{Disconnects: 3, Window: 15 * time.Minute}, ok: true},
+		{value: "5/PT30M", expected: controller.FlappingRule{Disconnects: 5, Window: 30 * time.Minute}, ok: true},
+		{value: "off", ok: false},
+		{value: "10", err: true},
+		{value: "0/1h", err: true},
+		{value: "x/1h", err: true},
+		{value: "10/foo", err: true},
+		{value: "10/0s", err: true},
+	}
+	for _, c := range cases {
+		actual, ok, err := controller.ParseFlappingRule(c.value)
+		if (err != nil) != c.err {
+			t.Error(c.value, err)
+			continue
+		}
+		if ok != c.ok || (ok && actual != c.expected) {
+			t.Error(c.value, ok, actual)
+		}
+	}
+}
+
+func TestFlappingRuleExceeded(t *testing.T) {
+	now := time.Now()
+	rule := controller.FlappingRule{Disconnects: 2, Window: time.Hour}
+	cases := []struct {
+		name        string
+		disconnects []time.Time
+		count       int
+		exceeded    bool
+	}{
+		{name: "none", count: 0, exceeded: false},
+		{name: "equal", disconnects: []time.Time{now.Add(-time.Minute), now}, count: 2, exceeded: false},
+		{name: "more", disconnects: []time.Time{now.Add(-time.Minute), now.Add(-time.Second), now}, count: 3, exceeded: true},
+		{name: "outside window", disconnects: []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Second), now}, count: 2, exceeded: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			count, exceeded := rule.Exceeded(c.disconnects, now)
+			if count != c.count || exceeded != c.exceeded {
+				t.Error(count, exceeded)
+			}
+		})
+	}
+}
+
+func TestFlappingNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.InitTopics = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func(id string, connected bool, flapping string) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:              id,
+			Connected:       connected,
+			Time:            time.Now(),
+			DeviceOwner:     "owner1",
+			DeviceName:      "device " + id,
+			MonitorFlapping: flapping,
+		})
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	for i := 0; i < 5; i++ {
+		//flapping, notified once per window
+		send("d1", false, "2/1m")
+		send("d1", true, "2/1m")
+		//below threshold
+		if i < 2 {
+			send("d2", false, "2/1m")
+			send("d2", true, "2/1m")
+		}
+		//without rule
+		send("d3", false, "")
+		send("d3", true, "")
+	}
+	//repeated offline messages are no transitions
+	for i := 0; i < 3; i++ {
+		send("d4", false, "2/1m")
+	}
+	time.Sleep(2 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Device Flapping\",\"message\":\"device device d1 (d1) disconnected 3 times within 1m0s\",\"topic\":\"device_flapping\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
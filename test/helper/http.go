@@ -19,6 +19,7 @@ package helper
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"runtime/debug"
 )
@@ -75,3 +76,31 @@ func AdminPost(url string, msg interface{}, result interface{}) (err error) {
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	return err
 }
+
+// AdminRequest sends msg (if not nil) as json and decodes the response into result (if not nil and the request was successful)
+func AdminRequest(method string, url string, msg interface{}, result interface{}) (status int, err error) {
+	var body io.Reader
+	if msg != nil {
+		b := new(bytes.Buffer)
+		err = json.NewEncoder(b).Encode(msg)
+		if err != nil {
+			return 0, err
+		}
+		body = b
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", AdminJwt)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 || result == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHubNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf.InitTopics = true
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	hubProducer, err := helper.GetProducer(broker, conf.HubTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hubProducer.Close()
+	hubLogProducer, err := helper.GetProducer(broker, conf.HubLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hubLogProducer.Close()
+	deviceLogProducer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deviceLogProducer.Close()
+
+	sendJsonMessage(t, hubProducer, "hub1", model.HubCommand{
+		Command: "PUT",
+		Id:      "hub1",
+		Owner:   "testowner",
+		Hub: model.Hub{
+			Id:        "hub1",
+			Name:      "hub 1",
+			OwnerId:   "testowner",
+			DeviceIds: []string{"d1", "d2", "d3"},
+			Attributes: []model.Attribute{
+				{Key: "monitor_connection_state", Value: "2s"},
+			},
+		},
+	})
+	sendJsonMessage(t, hubProducer, "hub2", model.HubCommand{
+		Command: "PUT",
+		Id:      "hub2",
+		Owner:   "testowner",
+		Hub: model.Hub{
+			Id:        "hub2",
+			Name:      "hub 2",
+			OwnerId:   "testowner",
+			DeviceIds: []string{"d4"},
+		},
+	})
+	sendFullDeviceLog(t, deviceLogProducer, model.DeviceLog{Id: "d1", Connected: true, Time: time.Now()})
+	sendFullDeviceLog(t, deviceLogProducer, model.DeviceLog{Id: "d2", Connected: false, Time: time.Now()})
+
+	time.Sleep(2 * time.Second)
+
+	sendJsonMessage(t, hubLogProducer, "hub1", model.HubLog{Id: "hub1", Connected: false, Time: time.Now()})
+	sendJsonMessage(t, hubLogProducer, "hub2", model.HubLog{Id: "hub2", Connected: false, Time: time.Now()})
+	sendJsonMessage(t, hubLogProducer, "hub3", model.HubLog{
+		Id:                     "hub3",
+		Connected:              false,
+		Time:                   time.Now(),
+		MonitorConnectionState: "2s",
+		HubOwner:               "testowner",
+		HubName:                "hub 3",
+	})
+
+	time.Sleep(3 * time.Second)
+
+	sendJsonMessage(t, hubLogProducer, "hub1", model.HubLog{Id: "hub1", Connected: false, Time: time.Now()})
+	sendJsonMessage(t, hubLogProducer, "hub2", model.HubLog{Id: "hub2", Connected: false, Time: time.Now()})
+	sendJsonMessage(t, hubLogProducer, "hub3", model.HubLog{
+		Id:                     "hub3",
+		Connected:              false,
+		Time:                   time.Now(),
+		MonitorConnectionState: "2s",
+		HubOwner:               "testowner",
+		HubName:                "hub 3",
+	})
+
+	time.Sleep(1 * time.Second)
+
+	//already notified
+	sendJsonMessage(t, hubLogProducer, "hub1", model.HubLog{Id: "hub1", Connected: false, Time: time.Now()})
+
+	time.Sleep(1 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"testowner\",\"title\":\"Gateway Offline\",\"message\":\"gateway hub 1 (hub1) has been offline for 3s; 1 of its 3 devices are unreachable\",\"topic\":\"gateway_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Gateway Offline\",\"message\":\"gateway hub 3 (hub3) has been offline for 3s; 0 of its 0 devices are unreachable\",\"topic\":\"gateway_offline\"}",
+	}
+
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
+
+func sendJsonMessage(t *testing.T, producer *kafka.Writer, key string, value interface{}) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = producer.WriteMessages(
+		context.Background(),
+		kafka.Message{
+			Key:   []byte(key),
+			Value: b,
+			Time:  time.Now(),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"reflect"
+	"testing"
+)
+
+type DeduplicatorMock map[string]bool
+
+func (this DeduplicatorMock) IsProcessed(ctx context.Context, key string) (bool, error) {
+	return this[key], nil
+}
+
+func (this DeduplicatorMock) MarkProcessed(ctx context.Context, key string) error {
+	this[key] = true
+	return nil
+}
+
+func TestDeduplication(t *testing.T) {
+	conf, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	control := &ControllerMock{}
+	handler, err := listener.DeviceLogListenerFactory(conf, conf.DeviceLogTopic, control)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	handler = listener.Deduplicate(DeduplicatorMock{}, handler, true)
+
+	messages := []listener.Message{
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 1, Value: []byte(`{"id":"d1","connected":true}`)},
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 1, Value: []byte(`{"id":"d1","connected":true}`)}, //replay
+		{Topic: conf.DeviceLogTopic, Partition: 1, Offset: 1, Value: []byte(`{"id":"d2","connected":true}`)},
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 2, Value: []byte(`{"specversion":"1.0","id":"e1","source":"test","data":{"id":"d3"}}`)},
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 3, Value: []byte(`{"specversion":"1.0","id":"e1","source":"test","data":{"id":"d3"}}`)}, //same event, produced twice
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 4, Value: []byte(`{"id":"d4"}`), Headers: map[string]string{"ce_specversion": "1.0", "ce_id": "e2", "ce_source": "test"}},
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 5, Value: []byte(`{"id":"d4"}`), Headers: map[string]string{"ce_specversion": "1.0", "ce_id": "e2", "ce_source": "test"}},
+		{Topic: conf.DeviceLogTopic, Partition: 0, Offset: 6, Value: []byte(`{"id":"d5"}`), Headers: map[string]string{"ce_specversion": "1.0", "ce_id": "e2", "ce_source": "other"}},
+	}
+	for _, msg := range messages {
+		err = handler(context.Background(), msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	ids := []string{}
+	for _, l := range control.DeviceLogs {
+		ids = append(ids, l.Id)
+	}
+	expected := []string{"d1", "d2", "d3", "d4", "d5"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("\n%#v\n%#v\n", expected, ids)
+	}
+}
+
+func TestDeduplicationAfterPartialFailure(t *testing.T) {
+	deduplicator := DeduplicatorMock{}
+	keys := []string{}
+	fail := true
+	handler := listener.Deduplicate(deduplicator, func(ctx context.Context, msg listener.Message) error {
+		keys = append(keys, model.MessageKeyFromContext(ctx))
+		if fail {
+			//side effects happened before the error
+			return errors.New("test error")
+		}
+		return nil
+	}, true)
+
+	msg := listener.Message{Topic: "device_log", Partition: 0, Offset: 1, Value: []byte(`{"id":"d1"}`)}
+	err := handler(context.Background(), msg)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if len(deduplicator) != 0 {
+		t.Error("failed message must not be marked as processed", deduplicator)
+	}
+	fail = false
+	for i := 0; i < 2; i++ {
+		err = handler(context.Background(), msg)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	//the redelivery is processed again with the same key, so that side effects recorded with the key are skipped
+	expected := []string{"offset:device_log:0:1", "offset:device_log:0:1"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("\n%#v\n%#v\n", expected, keys)
+	}
+
+	notification := notifier.Notification{UserId: "owner", Title: "Device Offline", Message: "device d1 has been offline for 2s", Topic: "device_offline"}
+	repeated := notification
+	repeated.Message = "device d1 has been offline for 3s"
+	id := controller.OutboxEntryId(keys[0], "notifier", notification)
+	if id != controller.OutboxEntryId(keys[1], "notifier", repeated) {
+		t.Error("expected the same outbox entry id for the repeated processing")
+	}
+	if id == controller.OutboxEntryId(keys[0], "mail", notification) || id == controller.OutboxEntryId("offset:device_log:0:2", "notifier", notification) {
+		t.Error("expected different outbox entry ids for other targets and messages")
+	}
+	if controller.OutboxEntryId("", "notifier", notification) == controller.OutboxEntryId("", "notifier", notification) {
+		t.Error("expected new ids outside of message processing")
+	}
+}
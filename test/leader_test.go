@@ -0,0 +1,71 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeaderElection(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mongoPort, _, err := server.MongoDB(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf := config.Config{
+		MongoUrl:                 "mongodb://localhost:" + mongoPort,
+		MongoTable:               "connectionlog",
+		LeaderElectionCollection: "leader_election",
+		LeaderElectionLease:      "1s",
+	}
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	defer cancel1()
+	control1 := controller.New(conf)
+	control1.Start(ctx1)
+
+	ctx2, cancel2 := context.WithCancel(ctx)
+	defer cancel2()
+	control2 := controller.New(conf)
+	control2.Start(ctx2)
+
+	time.Sleep(2 * time.Second)
+	if control1.IsLeader() == control2.IsLeader() {
+		t.Fatal("expected exactly one leader", control1.IsLeader(), control2.IsLeader())
+	}
+	leaderCancel, follower := cancel1, control2
+	if control2.IsLeader() {
+		leaderCancel, follower = cancel2, control1
+	}
+
+	leaderCancel()
+	time.Sleep(time.Second)
+	if !follower.IsLeader() {
+		t.Error("expected failover to the remaining instance")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"reflect"
+	"testing"
+)
+
+func TestMiddlewares(t *testing.T) {
+	conf, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = json.Unmarshal([]byte(`[
+		{"type": "deny", "options": {"id_prefixes": ["test-"], "owners": ["tester"]}},
+		{"type": "rewrite_ids", "options": {"mapping": {"old": "new"}, "trim_prefix": "urn:"}},
+		{"type": "allow", "options": {"ids": ["new", "d1", "d2", "h1"]}},
+		{"type": "debug_sample", "options": {"rate": 0.5}},
+		{"type": "enrich", "options": {"match": {"ids": ["d2", "h1"]}, "metadata": {"site": "a", "floor": "1"}, "monitor_connection_state": "1h"}}
+	]`), &conf.Middlewares)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mock := &ControllerMock{}
+	control, err := listener.ChainFromConfig(conf, mock)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx := context.Background()
+	for _, l := range []model.DeviceLog{
+		{Id: "test-1", Connected: true},
+		{Id: "d1", Connected: true, DeviceOwner: "tester"},
+		{Id: "old", Connected: true},
+		{Id: "urn:d1", Connected: true},
+		{Id: "d2", Connected: true, Metadata: map[string]string{"floor": "2"}},
+		{Id: "d3", Connected: true},
+	} {
+		err = control.LogDevice(ctx, l)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	err = control.LogHub(ctx, model.HubLog{Id: "h1", Connected: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = control.UpdateDevice(ctx, model.DeviceCommand{Command: "DELETE", Id: "test-2"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectedDeviceLogs := []model.DeviceLog{
+		{Id: "new", Connected: true},
+		{Id: "d1", Connected: true},
+		{Id: "d2", Connected: true, MonitorConnectionState: "1h", Metadata: map[string]string{"site": "a", "floor": "2"}},
+	}
+	if !reflect.DeepEqual(mock.DeviceLogs, expectedDeviceLogs) {
+		t.Errorf("\n%#v\n%#v\n", expectedDeviceLogs, mock.DeviceLogs)
+	}
+	expectedHubLogs := []model.HubLog{{Id: "h1", Connected: true, MonitorConnectionState: "1h", Metadata: map[string]string{"site": "a", "floor": "1"}}}
+	if !reflect.DeepEqual(mock.HubLogs, expectedHubLogs) {
+		t.Errorf("\n%#v\n%#v\n", expectedHubLogs, mock.HubLogs)
+	}
+	if len(mock.DeviceCommands) != 0 {
+		t.Errorf("%#v", mock.DeviceCommands)
+	}
+
+	conf.Middlewares = []config.MiddlewareConfig{{Type: "unknown"}}
+	_, err = listener.ChainFromConfig(conf, mock)
+	if err == nil {
+		t.Error("expected error for unknown middleware")
+	}
+}
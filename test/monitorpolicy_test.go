@@ -0,0 +1,133 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMonitorPolicy(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected []controller.MonitorLevel
+		err      bool
+	}{
+		{value: "10m", expected: []controller.MonitorLevel{{After: 10 * time.Minute}}},
+		{value: "1h, 10m,24h", expected: []controller.MonitorLevel{{After: 10 * time.Minute}, {After: time.Hour}, {After: 24 * time.Hour}}},
+		{
+			value: `[{"after":"1h","severity":"critical","topic":"device_offline_critical"},{"after":"10m","severity":"warning"}]`,
+			expected: []controller.MonitorLevel{
+				{After: 10 * time.Minute, Severity: "warning"},
+				{After: time.Hour, Severity: "critical", Topic: "device_offline_critical"},
+			},
+		},
+		{value: "PT1,5H", expected: []controller.MonitorLevel{{After: 90 * time.Minute}}},
+		{value: "10m, PT1,5H,P1,5D", expected: []controller.MonitorLevel{{After: 10 * time.Minute}, {After: 90 * time.Minute}, {After: 36 * time.Hour}}},
+		{value: "1d, 2w,P1DT2H", expected: []controller.MonitorLevel{{After: 24 * time.Hour}, {After: 26 * time.Hour}, {After: 14 * 24 * time.Hour}}},
+		{value: `[{"after":"PT10M"},{"after":"1d"}]`, expected: []controller.MonitorLevel{{After: 10 * time.Minute}, {After: 24 * time.Hour}}},
+		{value: "off", expected: nil},
+		{value: " Disabled ", expected: nil},
+		{value: "10m,foo", err: true},
+		{value: "[]", err: true},
+		{value: `[{"after":"foo"}]`, err: true},
+		{value: `[{"after":"10m"}`, err: true},
+	}
+	for _, c := range cases {
+		actual, err := controller.ParseMonitorPolicy(c.value)
+		if (err != nil) != c.err {
+			t.Error(c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%v\n%#v\n%#v\n", c.value, c.expected, actual)
+		}
+	}
+}
+
+func TestNextMonitorLevel(t *testing.T) {
+	levels, err := controller.ParseMonitorPolicy("10m,1h,24h")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cases := []struct {
+		since            time.Duration
+		notified         []string
+		expectedOk       bool
+		expectedLevel    time.Duration
+		expectedExceeded []string
+	}{
+		{since: 5 * time.Minute, expectedOk: false},
+		{since: 11 * time.Minute, expectedOk: true, expectedLevel: 10 * time.Minute, expectedExceeded: []string{"10m0s"}},
+		{since: 11 * time.Minute, notified: []string{"10m0s"}, expectedOk: false, expectedExceeded: []string{"10m0s"}},
+		{since: 2 * time.Hour, notified: []string{"10m0s"}, expectedOk: true, expectedLevel: time.Hour, expectedExceeded: []string{"10m0s", "1h0m0s"}},
+		{since: 48 * time.Hour, expectedOk: true, expectedLevel: 24 * time.Hour, expectedExceeded: []string{"10m0s", "1h0m0s", "24h0m0s"}},
+		{since: 48 * time.Hour, notified: []string{"10m0s", "1h0m0s", "24h0m0s"}, expectedOk: false, expectedExceeded: []string{"10m0s", "1h0m0s", "24h0m0s"}},
+	}
+	for _, c := range cases {
+		level, exceeded, ok := controller.NextMonitorLevel(levels, c.since, c.notified)
+		if ok != c.expectedOk || (ok && level.After != c.expectedLevel) || !reflect.DeepEqual(exceeded, c.expectedExceeded) {
+			t.Errorf("%v %v: %v %v %v", c.since, c.notified, level, exceeded, ok)
+		}
+	}
+}
+
+func TestParseMonitorDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"10m":        10 * time.Minute,
+		"1h30m":      90 * time.Minute,
+		"500ms":      500 * time.Millisecond,
+		"1d":         24 * time.Hour,
+		"1.5d":       36 * time.Hour,
+		"2w":         14 * 24 * time.Hour,
+		"1w2d3h4m5s": (9*24+3)*time.Hour + 4*time.Minute + 5*time.Second,
+		" 1d ":       24 * time.Hour,
+		"PT10M":      10 * time.Minute,
+		"P1DT2H":     26 * time.Hour,
+		"P2W":        14 * 24 * time.Hour,
+		"PT1,5H":     90 * time.Minute,
+		"pt30s":      30 * time.Second,
+		"P1Y":        365 * 24 * time.Hour,
+		"P1M":        30 * 24 * time.Hour,
+		"P1DT1H1M1S": 25*time.Hour + time.Minute + time.Second,
+	}
+	for value, expected := range cases {
+		actual, err := controller.ParseMonitorDuration(value)
+		if err != nil {
+			t.Error(value, err)
+			continue
+		}
+		if actual != expected {
+			t.Error(value, expected, actual)
+		}
+	}
+	for _, value := range []string{"", "foo", "1x", "1d foo", "d", "P", "PT", "P1H", "T1H", "-1d", "1 d"} {
+		_, err := controller.ParseMonitorDuration(value)
+		if !errors.Is(err, controller.ErrInvalidDuration) {
+			t.Error(value, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "ISO-8601") {
+			t.Error(value, err)
+		}
+	}
+}
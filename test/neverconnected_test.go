@@ -0,0 +1,139 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNeverConnectedNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.NeverConnectedCheckInterval = "500ms"
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	deviceProducer, err := helper.GetProducer(broker, conf.DeviceTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deviceProducer.Close()
+	deviceLogProducer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deviceLogProducer.Close()
+
+	put := func(id string, monitor string) {
+		attributes := []model.Attribute{}
+		if monitor != "" {
+			attributes = append(attributes, model.Attribute{Key: "monitor_connection_state", Value: monitor})
+		}
+		sendJsonMessage(t, deviceProducer, id, model.DeviceCommand{
+			Command: "PUT",
+			Id:      id,
+			Owner:   "owner1",
+			Device:  model.Device{Id: id, Name: "device " + id, OwnerId: "owner1", Attributes: attributes},
+		})
+	}
+
+	//never connects
+	put("d1", "2s")
+	//connects in time
+	put("d2", "2s")
+	//without monitor
+	put("d3", "")
+	//deleted before the deadline
+	put("d4", "2s")
+	//monitor removed by update
+	put("d5", "2s")
+	time.Sleep(time.Second)
+
+	put("d1", "2s")
+	sendFullDeviceLog(t, deviceLogProducer, model.DeviceLog{Id: "d2", Connected: true, Time: time.Now(), DeviceOwner: "owner1"})
+	sendJsonMessage(t, deviceProducer, "d4", model.DeviceCommand{Command: "DELETE", Id: "d4", Owner: "owner1"})
+	put("d5", "off")
+	time.Sleep(4 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Device Never Connected\",\"message\":\"device device d1 (d1) has not connected within 2s after its creation\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"github.com/SENERGY-Platform/connection-log-worker/lib"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
 	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
@@ -29,6 +30,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -270,3 +272,444 @@ func TestNotifications(t *testing.T) {
 		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
 	}
 }
+
+func TestReconnectNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.NotifyOnReconnect = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	start := time.Now()
+	for _, id := range []string{"id1", "id2", "id3"} {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              false,
+			Time:                   start,
+			MonitorConnectionState: "2s",
+			DeviceOwner:            "testowner",
+			DeviceName:             "device " + id,
+		})
+	}
+
+	time.Sleep(3 * time.Second)
+
+	//id1 is reconnected before the offline notification
+	sendFullDeviceLog(t, producer, model.DeviceLog{
+		Id:                     "id1",
+		Connected:              true,
+		Time:                   start.Add(time.Second),
+		MonitorConnectionState: "2s",
+		DeviceOwner:            "testowner",
+		DeviceName:             "device id1",
+	})
+	for _, id := range []string{"id2", "id3"} {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              false,
+			Time:                   time.Now(),
+			MonitorConnectionState: "2s",
+			DeviceOwner:            "testowner",
+			DeviceName:             "device " + id,
+		})
+	}
+
+	time.Sleep(1 * time.Second)
+
+	sendFullDeviceLog(t, producer, model.DeviceLog{
+		Id:                     "id2",
+		Connected:              true,
+		Time:                   start.Add(5 * time.Second),
+		MonitorConnectionState: "2s",
+		DeviceOwner:            "testowner",
+		DeviceName:             "device id2",
+	})
+	//disabled by device attribute
+	sendFullDeviceLog(t, producer, model.DeviceLog{
+		Id:                     "id3",
+		Connected:              true,
+		Time:                   start.Add(5 * time.Second),
+		MonitorConnectionState: "2s",
+		DeviceOwner:            "testowner",
+		DeviceName:             "device id3",
+		NotifyOnReconnect:      "false",
+	})
+
+	time.Sleep(1 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline\",\"message\":\"device device id2 (id2) has been offline for 3s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline\",\"message\":\"device device id3 (id3) has been offline for 3s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Device Online\",\"message\":\"device device id2 (id2) is back online after 5s\",\"topic\":\"device_offline\"}",
+	}
+
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
+
+func TestEscalatingNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	policies := map[string]string{
+		"id1": "2s,4s",
+		"id2": `[{"after":"2s","severity":"warning"},{"after":"4s","severity":"critical","topic":"device_offline_critical"}]`,
+	}
+	send := func() {
+		for _, id := range []string{"id1", "id2"} {
+			sendFullDeviceLog(t, producer, model.DeviceLog{
+				Id:                     id,
+				Connected:              false,
+				Time:                   time.Now(),
+				MonitorConnectionState: policies[id],
+				DeviceOwner:            "testowner",
+				DeviceName:             "device " + id,
+			})
+		}
+	}
+
+	send()
+	time.Sleep(3 * time.Second)
+	send()
+	time.Sleep(2 * time.Second)
+	send()
+	time.Sleep(1 * time.Second)
+	send()
+	time.Sleep(1 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline\",\"message\":\"device device id1 (id1) has been offline for 3s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline (warning)\",\"message\":\"device device id2 (id2) has been offline for 3s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline\",\"message\":\"device device id1 (id1) has been offline for 5s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"testowner\",\"title\":\"Device Offline (critical)\",\"message\":\"device device id2 (id2) has been offline for 5s\",\"topic\":\"device_offline_critical\"}",
+	}
+
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
+
+func TestDigestNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.DigestWindow = "3s"
+	defaultConfig.DigestMaxDevices = 2
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	owners := map[string]string{"id1": "owner1", "id2": "owner1", "id3": "owner1", "id4": "owner1", "id5": "owner2"}
+	send := func() {
+		for _, id := range []string{"id1", "id2", "id3", "id4", "id5"} {
+			sendFullDeviceLog(t, producer, model.DeviceLog{
+				Id:                     id,
+				Connected:              false,
+				Time:                   time.Now(),
+				MonitorConnectionState: "1s",
+				DeviceOwner:            owners[id],
+				DeviceName:             "device " + id,
+			})
+		}
+	}
+
+	send()
+	time.Sleep(2 * time.Second)
+	send()
+
+	//reconnected devices are removed from pending digests
+	sendFullDeviceLog(t, producer, model.DeviceLog{
+		Id:                     "id4",
+		Connected:              true,
+		Time:                   time.Now(),
+		MonitorConnectionState: "1s",
+		DeviceOwner:            owners["id4"],
+		DeviceName:             "device id4",
+	})
+
+	time.Sleep(1 * time.Second)
+	mux.Lock()
+	if len(notifications) != 0 {
+		t.Error("unexpected notifications before the digest window is closed", notifications)
+	}
+	mux.Unlock()
+
+	time.Sleep(5 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+	sort.Strings(notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"3 Devices Offline\",\"message\":\"3 devices have gone offline:\\n- device id1 (id1) offline for 2s\\n- device id2 (id2) offline for 2s\\nand 1 more\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"owner2\",\"title\":\"Device Offline\",\"message\":\"device device id5 (id5) has been offline for 2s\",\"topic\":\"device_offline\"}",
+	}
+
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
+
+func TestNotificationOutbox(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.NotificationOutboxCollection = "notification_outbox"
+	defaultConfig.NotificationOutboxRetryInterval = "200ms"
+	defaultConfig.NotificationOutboxMaxBackoff = "400ms"
+	defaultConfig.NotificationOutboxMaxAge = "3s"
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	attempts := map[string]int{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notification := strings.TrimSpace(string(temp))
+		attempts[notification] = attempts[notification] + 1
+		//the notifier is unavailable for the first two attempts of every notification and always for owner2
+		if attempts[notification] <= 2 || strings.Contains(notification, "owner2") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		notifications = append(notifications, notification)
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	owners := map[string]string{"id1": "owner1", "id2": "owner2"}
+	send := func(monitor string) {
+		for _, id := range []string{"id1", "id2"} {
+			sendFullDeviceLog(t, producer, model.DeviceLog{
+				Id:                     id,
+				Connected:              false,
+				Time:                   time.Now(),
+				MonitorConnectionState: monitor,
+				DeviceOwner:            owners[id],
+				DeviceName:             "device " + id,
+			})
+		}
+	}
+
+	send("1s")
+	time.Sleep(2 * time.Second)
+	send("1s")
+	send("foo")
+
+	time.Sleep(6 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+	t.Logf("%#v\n", attempts)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Device Offline\",\"message\":\"device device id1 (id1) has been offline for 2s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"owner1\",\"title\":\"Device monitor_connection_state Attribute Invalid\",\"message\":\"device device id1 (id1) has an invalid monitor_connection_state attribute; error = invalid duration \\\"foo\\\"; " + strings.ReplaceAll(controller.MonitorFormatDescription, "\"", "\\\"") + "\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+	for notification, count := range attempts {
+		if strings.Contains(notification, "owner1") && count != 3 {
+			t.Error("unexpected attempts", count, notification)
+		}
+		//dropped after NotificationOutboxMaxAge
+		if strings.Contains(notification, "owner2") && (count < 4 || count > 12) {
+			t.Error("unexpected attempts", count, notification)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/notifier"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationChannels(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := sync.Mutex{}
+	serviceCalls := []string{}
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		serviceCalls = append(serviceCalls, r.URL.String()+" "+strings.TrimSpace(string(temp)))
+	}))
+	defer service.Close()
+
+	webhookCalls := []string{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		expected := notifier.SignWebhookPayload("secret", r.Header.Get(notifier.WebhookTimestampHeader), temp)
+		if r.Header.Get(notifier.WebhookSignatureHeader) != expected {
+			t.Error("invalid signature", r.Header.Get(notifier.WebhookSignatureHeader), expected)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		webhookCalls = append(webhookCalls, string(temp))
+	}))
+	defer webhook.Close()
+
+	smtpServer, err := server.NewSmtpServer(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := config.Config{
+		NotificationUrl: service.URL,
+		NotificationChannels: map[string]config.NotificationChannelConfig{
+			"hook": {Type: notifier.WebhookType, Options: json.RawMessage(`{"url":"` + webhook.URL + `","secret":"secret"}`)},
+			"mail": {Type: notifier.SmtpType, Options: json.RawMessage(`{"host":"` + smtpServer.Host + `","port":` + strconv.Itoa(smtpServer.Port) + `,"from":"worker@example.com","recipients":{"owner1":"owner1@example.com"}}`)},
+		},
+	}
+	channels, err := notifier.New(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	notification := notifier.Notification{UserId: "owner1", Title: "Gerät offline", Message: "line1\nline2", Topic: "device_offline"}
+
+	t.Run("default", func(t *testing.T) {
+		err = channels.Notify(ctx, "", notification)
+		if err != nil {
+			t.Error(err)
+		}
+		temp := notification
+		temp.IgnoreDuplicatesWithin = 24 * time.Hour
+		err = channels.Notify(ctx, "notifier", temp)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("webhook and mail", func(t *testing.T) {
+		err = channels.Notify(ctx, "hook, mail", notification)
+		if err != nil {
+			t.Error(err)
+		}
+		err = channels.Notify(ctx, "mail:other@example.com", notification)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		err = channels.Notify(ctx, "unknown,hook", notification)
+		if err == nil {
+			t.Error("expected error for unknown channel")
+		}
+		temp := notification
+		temp.UserId = "owner2"
+		err = channels.Notify(ctx, "mail", temp)
+		if err == nil {
+			t.Error("expected error for missing mail address")
+		}
+		_, err = notifier.New(config.Config{NotificationChannels: map[string]config.NotificationChannelConfig{"foo": {Type: "foo"}}})
+		if err == nil {
+			t.Error("expected error for unknown channel type")
+		}
+	})
+
+	mux.Lock()
+	defer mux.Unlock()
+	expectedPayload := `{"userId":"owner1","title":"Gerät offline","message":"line1\nline2","topic":"device_offline"}`
+	expectedServiceCalls := []string{
+		"/notifications " + expectedPayload,
+		"/notifications?ignore_duplicates_within_seconds=86400 " + expectedPayload,
+	}
+	if !reflect.DeepEqual(serviceCalls, expectedServiceCalls) {
+		t.Errorf("\n%#v\n%#v\n", expectedServiceCalls, serviceCalls)
+	}
+	expectedWebhookCalls := []string{expectedPayload, expectedPayload}
+	if !reflect.DeepEqual(webhookCalls, expectedWebhookCalls) {
+		t.Errorf("\n%#v\n%#v\n", expectedWebhookCalls, webhookCalls)
+	}
+	mails := smtpServer.Mails()
+	if len(mails) != 2 {
+		t.Error(mails)
+		return
+	}
+	if !reflect.DeepEqual(mails[0].To, []string{"owner1@example.com"}) || !reflect.DeepEqual(mails[1].To, []string{"other@example.com"}) || mails[0].From != "worker@example.com" {
+		t.Errorf("%#v", mails)
+	}
+	if !strings.Contains(mails[0].Data, "Subject: =?utf-8?q?Ger=C3=A4t_offline?=\n") || !strings.HasSuffix(mails[0].Data, "\n\nline1\nline2\n") {
+		t.Errorf("%#v", mails[0].Data)
+	}
+}
+
+func TestMqttNotifier(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	brokerUrl, err := server.Mosquitto(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	received := []string{}
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(brokerUrl).SetClientID("test-subscriber"))
+	token := client.Connect()
+	if token.Wait() && token.Error() != nil {
+		t.Error(token.Error())
+		return
+	}
+	defer client.Disconnect(0)
+	token = client.Subscribe("#", 2, func(client paho.Client, message paho.Message) {
+		mux.Lock()
+		defer mux.Unlock()
+		received = append(received, message.Topic()+" "+string(message.Payload()))
+	})
+	if token.Wait() && token.Error() != nil {
+		t.Error(token.Error())
+		return
+	}
+
+	channels, err := notifier.New(config.Config{
+		NotificationChannels: map[string]config.NotificationChannelConfig{
+			"mqtt": {Type: notifier.MqttType, Options: json.RawMessage(`{"broker":"` + brokerUrl + `","qos":2}`)},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	notification := notifier.Notification{UserId: "owner1", Title: "Device Offline", Message: "msg", Topic: "device_offline"}
+	err = channels.Notify(ctx, "mqtt", notification)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = channels.Notify(ctx, "mqtt:alerts/{topic}/{userId}", notification)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	payload := `{"userId":"owner1","title":"Device Offline","message":"msg","topic":"device_offline"}`
+	expected := []string{
+		"notifications/owner1 " + payload,
+		"alerts/device_offline/owner1 " + payload,
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("\n%#v\n%#v\n", expected, received)
+	}
+}
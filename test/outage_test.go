@@ -0,0 +1,332 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutageThresholdReached(t *testing.T) {
+	cases := []struct {
+		name       string
+		devices    []string
+		hubs       []string
+		total      int
+		minDevices int64
+		percent    float64
+		expected   bool
+	}{
+		{name: "single device", devices: []string{"d1"}, total: 10, minDevices: 5, percent: 50, expected: false},
+		{name: "min devices", devices: []string{"d1", "d2", "d3", "d4", "d5"}, total: 100, minDevices: 5, percent: 50, expected: true},
+		{name: "below min devices", devices: []string{"d1", "d2", "d3", "d4"}, total: 100, minDevices: 5, percent: 50, expected: false},
+		{name: "percent", devices: []string{"d1", "d2"}, total: 3, minDevices: 5, percent: 50, expected: true},
+		{name: "exactly percent", devices: []string{"d1", "d2"}, total: 4, minDevices: 5, percent: 50, expected: false},
+		{name: "percent needs two devices", devices: []string{"d1"}, total: 1, minDevices: 5, percent: 50, expected: false},
+		{name: "unknown total", devices: []string{"d1", "d2"}, total: 0, minDevices: 5, percent: 50, expected: false},
+		{name: "hub with device", devices: []string{"d1"}, hubs: []string{"h1"}, total: 10, minDevices: 5, percent: 50, expected: false},
+		{name: "hub with min devices", devices: []string{"d1", "d2", "d3", "d4", "d5"}, hubs: []string{"h1"}, total: 10, minDevices: 5, percent: 50, expected: true},
+		{name: "hub without devices", hubs: []string{"h1"}, total: 10, minDevices: 5, percent: 50, expected: false},
+		{name: "disabled rules", devices: []string{"d1", "d2", "d3"}, total: 3, minDevices: 0, percent: 0, expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := controller.OutageThresholdReached(controller.Outage{AffectedDevices: c.devices, AffectedHubs: c.hubs}, c.total, c.minDevices, c.percent)
+			if actual != c.expected {
+				t.Error(actual)
+			}
+		})
+	}
+}
+
+func TestOutageNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1m"
+	defaultConfig.InitTopics = true
+	defaultConfig.OutageWindow = "10s"
+	defaultConfig.OutageMinDevices = 3
+	defaultConfig.OutagePercent = 0
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func(id string, owner string, connected bool) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              connected,
+			Time:                   time.Now(),
+			MonitorConnectionState: "1s",
+			DeviceOwner:            owner,
+			DeviceName:             "device " + id,
+		})
+	}
+
+	//online states are needed to count the devices of the owners
+	for _, id := range []string{"d1", "d2", "d3", "d4"} {
+		send(id, "owner1", true)
+	}
+	send("d5", "owner2", true)
+	time.Sleep(time.Second)
+
+	//outage of owner1; d5 goes offline alone
+	for _, id := range []string{"d1", "d2", "d3"} {
+		send(id, "owner1", false)
+	}
+	send("d5", "owner2", false)
+	time.Sleep(2 * time.Second)
+
+	//repeated offline messages trigger the notification checks
+	for _, id := range []string{"d1", "d2", "d3"} {
+		send(id, "owner1", false)
+	}
+	send("d5", "owner2", false)
+	time.Sleep(2 * time.Second)
+
+	session, err := mgo.Dial(conf.MongoUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	outages := []controller.Outage{}
+	err = session.DB(conf.MongoTable).C(conf.OutageCollection).Find(bson.M{"detected": true}).All(&outages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outages) != 1 {
+		t.Fatal(outages)
+	}
+	outage := outages[0]
+	slices.Sort(outage.AffectedDevices)
+	if outage.Scope != controller.OutageScopeOwner || outage.TargetId != "owner1" || outage.End != nil || !outage.Notified || !reflect.DeepEqual(outage.AffectedDevices, []string{"d1", "d2", "d3"}) {
+		t.Errorf("%#v\n", outage)
+	}
+
+	//the outage ends when all affected devices reconnected
+	for _, id := range []string{"d1", "d2", "d3"} {
+		send(id, "owner1", true)
+	}
+	time.Sleep(2 * time.Second)
+	err = session.DB(conf.MongoTable).C(conf.OutageCollection).FindId(outage.Id).One(&outage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outage.End == nil || len(outage.Offline) != 0 {
+		t.Errorf("%#v\n", outage)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Outage\",\"message\":\"3 devices went offline at the same time; 3 are still unreachable after 0s. individual device notifications are replaced by this notification\",\"topic\":\"outage\"}",
+		"{\"userId\":\"owner2\",\"title\":\"Device Offline\",\"message\":\"device device d5 (d5) has been offline for 0s\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
+
+func TestOutageDeduplication(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1m"
+	defaultConfig.InitTopics = true
+	defaultConfig.OutageWindow = "10s"
+	defaultConfig.OutageMinDevices = 3
+	defaultConfig.OutagePercent = 0
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	hubProducer, err := helper.GetProducer(broker, conf.HubTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hubProducer.Close()
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	sendJsonMessage(t, hubProducer, "hub1", model.HubCommand{
+		Command: "PUT",
+		Id:      "hub1",
+		Owner:   "owner1",
+		Hub: model.Hub{
+			Id:        "hub1",
+			Name:      "hub 1",
+			OwnerId:   "owner1",
+			DeviceIds: []string{"d1", "d2", "d3"},
+		},
+	})
+	time.Sleep(time.Second)
+
+	send := func(id string, connected bool) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     id,
+			Connected:              connected,
+			Time:                   time.Now(),
+			MonitorConnectionState: "1s",
+			DeviceOwner:            "owner1",
+			DeviceName:             "device " + id,
+		})
+	}
+
+	//the drop of the hub devices is a hub and an owner outage; only the hub outage is reported
+	for _, id := range []string{"d1", "d2", "d3"} {
+		send(id, false)
+	}
+	time.Sleep(2 * time.Second)
+	for _, id := range []string{"d1", "d2", "d3"} {
+		send(id, false)
+	}
+	time.Sleep(2 * time.Second)
+
+	session, err := mgo.Dial(conf.MongoUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	outages := []controller.Outage{}
+	err = session.DB(conf.MongoTable).C(conf.OutageCollection).Find(bson.M{"detected": true}).All(&outages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outages) != 1 || outages[0].Scope != controller.OutageScopeHub || outages[0].TargetId != "hub1" {
+		t.Errorf("%#v\n", outages)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+	outageNotifications := 0
+	for _, notification := range notifications {
+		if strings.Contains(notification, "\"topic\":\"outage\"") {
+			outageNotifications++
+		}
+	}
+	if outageNotifications != 1 {
+		t.Errorf("expected one outage notification: %v", notifications)
+	}
+}
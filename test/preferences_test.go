@@ -0,0 +1,268 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/api"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationPreferencesValidate(t *testing.T) {
+	schedule := &model.RecurringSchedule{StartTime: "22:00", EndTime: "06:00", Timezone: "Europe/Berlin"}
+	cases := []struct {
+		preferences model.NotificationPreferences
+		valid       bool
+	}{
+		{preferences: model.NotificationPreferences{UserId: "u"}, valid: true},
+		{preferences: model.NotificationPreferences{UserId: "u", Delivery: model.DeliveryImmediate, QuietHours: schedule, MutedDevices: []string{"d1"}}, valid: true},
+		{preferences: model.NotificationPreferences{}, valid: false},
+		{preferences: model.NotificationPreferences{UserId: "u", Delivery: "weekly"}, valid: false},
+		{preferences: model.NotificationPreferences{UserId: "u", MutedDevices: []string{""}}, valid: false},
+		{preferences: model.NotificationPreferences{UserId: "u", QuietHours: &model.RecurringSchedule{StartTime: "22", EndTime: "06:00"}}, valid: false},
+	}
+	for i, c := range cases {
+		err := c.preferences.Validate()
+		if (err == nil) != c.valid {
+			t.Error(i, err)
+		}
+	}
+	if !(model.NotificationPreferences{MutedDevices: []string{"d1"}}).Muted("d1") || (model.NotificationPreferences{MutedDevices: []string{"d1"}}).Muted("") {
+		t.Error("unexpected Muted() result")
+	}
+}
+
+func TestRecurringScheduleActiveUntil(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := model.RecurringSchedule{Weekdays: []string{"fri"}, StartTime: "22:00", EndTime: "06:00", Timezone: "Europe/Berlin"}
+	cases := []struct {
+		time   time.Time
+		active bool
+		end    time.Time
+	}{
+		{time: time.Date(2025, 1, 3, 23, 0, 0, 0, location), active: true, end: time.Date(2025, 1, 4, 6, 0, 0, 0, location)},
+		{time: time.Date(2025, 1, 4, 5, 59, 0, 0, location), active: true, end: time.Date(2025, 1, 4, 6, 0, 0, 0, location)},
+		{time: time.Date(2025, 1, 4, 6, 0, 0, 0, location), active: false},
+		{time: time.Date(2025, 1, 4, 23, 0, 0, 0, location), active: false},
+	}
+	for _, c := range cases {
+		end, active := schedule.ActiveUntil(c.time)
+		if active != c.active || !end.Equal(c.end) {
+			t.Error(c.time, active, end)
+		}
+	}
+}
+
+func TestNotificationPreferencesApi(t *testing.T) {
+	mock := NewApiControllerMock()
+	conf := config.Config{NotificationPreferenceCollection: "preferences", NotificationOutboxCollection: "outbox", NotificationChannels: map[string]config.NotificationChannelConfig{"mail": {Type: "smtp"}}}
+	s := httptest.NewServer(api.GetRouter(conf, mock))
+	defer s.Close()
+
+	//quiet hours are rejected without outbox, because notifications would be dropped
+	conf.NotificationOutboxCollection = "-"
+	withoutOutbox := httptest.NewServer(api.GetRouter(conf, mock))
+	defer withoutOutbox.Close()
+	status, err := helper.AdminRequest("PUT", withoutOutbox.URL+"/notifications/preferences", model.NotificationPreferences{
+		QuietHours: &model.RecurringSchedule{StartTime: "22:00", EndTime: "06:00"},
+	}, nil)
+	if err != nil || status != http.StatusBadRequest {
+		t.Error(status, err)
+	}
+
+	resp, err := http.Get(s.URL + "/notifications/preferences")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error(resp.StatusCode)
+	}
+
+	adminId := "dd69ea0d-f553-4336-80f3-7f4567f85c7b" //sub of helper.AdminJwt
+	preferences := model.NotificationPreferences{}
+	status, err = helper.AdminRequest("GET", s.URL+"/notifications/preferences", nil, &preferences)
+	if err != nil || status != http.StatusOK || !reflect.DeepEqual(preferences, model.NotificationPreferences{UserId: adminId}) {
+		t.Errorf("%v %v %#v", status, err, preferences)
+	}
+
+	for _, invalid := range []model.NotificationPreferences{
+		{UserId: "other"},
+		{Delivery: "weekly"},
+		{Channel: "unknown"},
+		{Channel: "mail:someone@example.com"},
+		{QuietHours: &model.RecurringSchedule{StartTime: "22:00", EndTime: "6"}},
+	} {
+		status, err = helper.AdminRequest("PUT", s.URL+"/notifications/preferences", invalid, nil)
+		if err != nil || status != http.StatusBadRequest {
+			t.Error(status, err, invalid)
+		}
+	}
+
+	expected := model.NotificationPreferences{
+		UserId:       adminId,
+		Channel:      "notifier, mail",
+		Delivery:     model.DeliveryImmediate,
+		QuietHours:   &model.RecurringSchedule{StartTime: "22:00", EndTime: "06:00", Timezone: "Europe/Berlin"},
+		Locale:       "de",
+		MutedDevices: []string{"d1"},
+	}
+	input := expected
+	input.UserId = ""
+	status, err = helper.AdminRequest("PUT", s.URL+"/notifications/preferences", input, nil)
+	if err != nil || status != http.StatusOK {
+		t.Error(status, err)
+	}
+	preferences = model.NotificationPreferences{}
+	status, err = helper.AdminRequest("GET", s.URL+"/notifications/preferences", nil, &preferences)
+	if err != nil || status != http.StatusOK || !reflect.DeepEqual(preferences, expected) {
+		t.Errorf("%v %v %#v", status, err, preferences)
+	}
+
+	status, err = helper.AdminRequest("DELETE", s.URL+"/notifications/preferences", nil, nil)
+	if err != nil || status != http.StatusNoContent || len(mock.Preferences) != 0 {
+		t.Error(status, err, mock.Preferences)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+	defaultConfig.DigestWindow = "3s"
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	adminId := "dd69ea0d-f553-4336-80f3-7f4567f85c7b" //sub of helper.AdminJwt
+	status, err := helper.AdminRequest("PUT", "http://localhost:"+conf.ApiPort+"/notifications/preferences", model.NotificationPreferences{
+		Delivery:     model.DeliveryImmediate,
+		Locale:       "de",
+		MutedDevices: []string{"id2"},
+	}, nil)
+	if err != nil || status != http.StatusOK {
+		t.Fatal(status, err)
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	owners := map[string]string{"id1": adminId, "id2": adminId, "id3": "owner2"}
+	send := func() {
+		for _, id := range []string{"id1", "id2", "id3"} {
+			sendFullDeviceLog(t, producer, model.DeviceLog{
+				Id:                     id,
+				Connected:              false,
+				Time:                   time.Now(),
+				MonitorConnectionState: "1s",
+				DeviceOwner:            owners[id],
+				DeviceName:             "device " + id,
+			})
+		}
+	}
+
+	send()
+	time.Sleep(2 * time.Second)
+	send()
+
+	time.Sleep(1 * time.Second)
+	mux.Lock()
+	expectedImmediate := []string{
+		"{\"userId\":\"" + adminId + "\",\"title\":\"Gerät offline\",\"message\":\"Gerät device id1 (id1) ist seit 2s offline\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expectedImmediate) {
+		t.Errorf("\ne:%v\na:%v\n", expectedImmediate, notifications)
+	}
+	mux.Unlock()
+
+	time.Sleep(5 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+	sort.Strings(notifications)
+
+	expected := []string{
+		"{\"userId\":\"" + adminId + "\",\"title\":\"Gerät offline\",\"message\":\"Gerät device id1 (id1) ist seit 2s offline\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"owner2\",\"title\":\"Device Offline\",\"message\":\"device device id3 (id3) has been offline for 2s\",\"topic\":\"device_offline\"}",
+	}
+
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
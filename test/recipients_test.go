@@ -0,0 +1,240 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/api"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	permv2 "github.com/SENERGY-Platform/permissions-v2/pkg/client"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationRecipients(t *testing.T) {
+	permissions := permv2.ResourcePermissions{
+		UserPermissions: map[string]permv2.PermissionsMap{
+			"owner": {Read: true, Write: true, Execute: true, Administrate: true},
+			"user3": {Read: true, Execute: true},
+			"user2": {Read: true},
+			"user1": {Execute: true},
+		},
+		GroupPermissions: map[string]permv2.PermissionsMap{"group": {Read: true}},
+	}
+	cases := []struct {
+		owner    string
+		required string
+		expected []string
+	}{
+		{owner: "owner", required: "r", expected: []string{"owner", "user2", "user3"}},
+		{owner: "owner", required: "rx", expected: []string{"owner", "user3"}},
+		{owner: "owner", required: "a", expected: []string{"owner"}},
+		{owner: "user1", required: "x", expected: []string{"user1", "owner", "user3"}},
+		{owner: "", required: "x", expected: []string{"owner", "user1", "user3"}},
+		{owner: "owner", required: "", expected: []string{"owner", "user1", "user2", "user3"}},
+	}
+	for _, c := range cases {
+		actual := controller.NotificationRecipients(c.owner, permissions, c.required)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Error(c.owner, c.required, actual)
+		}
+	}
+	if controller.ValidateNotificationPermission("rwxa") != nil || controller.ValidateNotificationPermission("rq") == nil {
+		t.Error("unexpected ValidateNotificationPermission() result")
+	}
+}
+
+func TestNotificationOptOutApi(t *testing.T) {
+	mock := NewApiControllerMock()
+	s := httptest.NewServer(api.GetRouter(config.Config{NotificationOptOutCollection: "opt_outs"}, mock))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/notifications/opt-outs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error(resp.StatusCode)
+	}
+
+	adminId := "dd69ea0d-f553-4336-80f3-7f4567f85c7b" //sub of helper.AdminJwt
+	for _, resourceId := range []string{"d1", "*", "d1"} {
+		status, err := helper.AdminRequest("PUT", s.URL+"/notifications/opt-outs/"+resourceId, nil, nil)
+		if err != nil || status != http.StatusOK {
+			t.Error(status, err)
+		}
+	}
+	mock.OptOuts = append(mock.OptOuts, model.NotificationOptOut{UserId: "other", ResourceId: "d2"})
+
+	list := []model.NotificationOptOut{}
+	status, err := helper.AdminRequest("GET", s.URL+"/notifications/opt-outs", nil, &list)
+	slices.SortFunc(list, func(a, b model.NotificationOptOut) int {
+		return strings.Compare(a.ResourceId, b.ResourceId)
+	})
+	expected := []model.NotificationOptOut{{UserId: adminId, ResourceId: "*"}, {UserId: adminId, ResourceId: "d1"}}
+	if err != nil || status != http.StatusOK || !reflect.DeepEqual(list, expected) {
+		t.Errorf("%v %v %#v", status, err, list)
+	}
+
+	status, err = helper.AdminRequest("DELETE", s.URL+"/notifications/opt-outs/*", nil, nil)
+	if err != nil || status != http.StatusNoContent {
+		t.Error(status, err)
+	}
+	status, err = helper.AdminRequest("GET", s.URL+"/notifications/opt-outs", nil, &list)
+	if err != nil || status != http.StatusOK || !reflect.DeepEqual(list, []model.NotificationOptOut{{UserId: adminId, ResourceId: "d1"}}) {
+		t.Errorf("%v %v %#v", status, err, list)
+	}
+}
+
+func TestSharedDeviceNotifications(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1s"
+	defaultConfig.InitTopics = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, permV2Ip, err := server.PermissionsV2(ctx, wg, conf.MongoUrl, conf.KafkaUrl)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.PermissionsV2Url = "http://" + permV2Ip + ":8080"
+	conf.NotificationPermission = "r"
+
+	permissions := permv2.New(conf.PermissionsV2Url)
+	_, err, _ = permissions.SetTopic(permv2.InternalAdminToken, permv2.Topic{Id: conf.DeviceTopic})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err, _ = permissions.SetPermission(permv2.InternalAdminToken, conf.DeviceTopic, "d1", permv2.ResourcePermissions{
+		UserPermissions: map[string]permv2.PermissionsMap{
+			"owner1": {Read: true, Write: true, Execute: true, Administrate: true},
+			"user2":  {Read: true},
+			"user3":  {Read: true, Execute: true},
+			"user4":  {Execute: true},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	//user3 opts out
+	user3Jwt := "Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyMyJ9.sig"
+	req, _ := http.NewRequest("PUT", "http://localhost:"+conf.ApiPort+"/notifications/opt-outs/d1", nil)
+	req.Header.Set("Authorization", user3Jwt)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Error(resp.StatusCode)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func() {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:                     "d1",
+			Connected:              false,
+			Time:                   time.Now(),
+			MonitorConnectionState: "1s",
+			DeviceOwner:            "owner1",
+			DeviceName:             "device 1",
+		})
+	}
+	send()
+	time.Sleep(2 * time.Second)
+	send()
+	time.Sleep(1 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Device Offline\",\"message\":\"device device 1 (d1) has been offline for 2s\",\"topic\":\"device_offline\"}",
+		"{\"userId\":\"user2\",\"title\":\"Device Offline\",\"message\":\"device device 1 (d1) has been offline for 2s\",\"topic\":\"device_offline\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer/listener"
+	"reflect"
+	"testing"
+)
+
+func TestListenerRegistry(t *testing.T) {
+	conf, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	topics := func(listeners []listener.EnabledListener) map[string]string {
+		result := map[string]string{}
+		for _, l := range listeners {
+			result[l.Name] = l.Topic
+		}
+		return result
+	}
+
+	t.Run("default", func(t *testing.T) {
+		listeners, err := listener.DefaultRegistry().Enabled(conf)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := map[string]string{
+			listener.DeviceLogListenerName: conf.DeviceLogTopic,
+			listener.HubLogListenerName:    conf.HubLogTopic,
+			listener.DevicesListenerName:   conf.DeviceTopic,
+			listener.HubsListenerName:      conf.HubTopic,
+		}
+		if !reflect.DeepEqual(topics(listeners), expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, topics(listeners))
+		}
+	})
+
+	t.Run("subset", func(t *testing.T) {
+		c := conf
+		c.EnabledListeners = []string{listener.DeviceLogListenerName, listener.HubLogListenerName}
+		c.DisabledListeners = []string{listener.HubLogListenerName}
+		c.ListenerTopics = map[string]string{listener.DeviceLogListenerName: "other_device_log"}
+		listeners, err := listener.DefaultRegistry().Enabled(c)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := map[string]string{listener.DeviceLogListenerName: "other_device_log"}
+		if !reflect.DeepEqual(topics(listeners), expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, topics(listeners))
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		c := conf
+		c.DisabledListeners = []string{"unknown"}
+		_, err := listener.DefaultRegistry().Enabled(c)
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		registry := listener.DefaultRegistry()
+		err := registry.Register(listener.Registration{
+			Name: "legacy_device_log",
+			Factory: func(config config.Config, topic string, control listener.Controller) (listener.Listener, error) {
+				return func(ctx context.Context, msg listener.Message) error {
+					legacy := struct {
+						DeviceId string `json:"device_id"`
+						Online   bool   `json:"online"`
+					}{}
+					err := json.Unmarshal(msg.Value, &legacy)
+					if err != nil {
+						return err
+					}
+					return control.LogDevice(ctx, model.DeviceLog{Id: legacy.DeviceId, Connected: legacy.Online})
+				}, nil
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = registry.Register(listener.Registration{Name: listener.DeviceLogListenerName, Factory: listener.DeviceLogListenerFactory})
+		if err == nil {
+			t.Error("expected duplicate error")
+			return
+		}
+
+		c := conf
+		c.EnabledListeners = []string{"legacy_device_log"}
+		_, err = registry.Enabled(c)
+		if err == nil {
+			t.Error("expected missing topic error")
+			return
+		}
+
+		c.ListenerTopics = map[string]string{"legacy_device_log": "legacy"}
+		listeners, err := registry.Enabled(c)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(listeners) != 1 || listeners[0].Topic != "legacy" {
+			t.Errorf("%#v", listeners)
+			return
+		}
+		control := &ControllerMock{}
+		handler, err := listeners[0].Factory(c, listeners[0].Topic, control)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = handler(context.Background(), listener.Message{Topic: "legacy", Value: []byte(`{"device_id":"d1","online":true}`)})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := []model.DeviceLog{{Id: "d1", Connected: true}}
+		if !reflect.DeepEqual(control.DeviceLogs, expected) {
+			t.Errorf("\n%#v\n%#v\n", expected, control.DeviceLogs)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvaluateNotificationRules(t *testing.T) {
+	rules := []config.NotificationRule{
+		{Name: "business-hours", Condition: `!connected && weekday not in ["Sat", "Sun"] && hour >= 8 && hour < 18`},
+		{Name: "site-a", Type: controller.RuleTypeDevice, Condition: `!connected && metadata.site == "a"`},
+		{Name: "hub-online", Type: controller.RuleTypeDevice, Condition: `!connected && hub.known && hub.online`},
+		{Name: "unstable", Condition: `history.disconnects_1h > 3`},
+		{Name: "long-offline", Condition: `connected && previous_state_duration > duration("1h")`},
+		{Name: "hubs", Type: controller.RuleTypeHub, Condition: `!connected`},
+	}
+	monday := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		env      controller.RuleEnv
+		expected []string
+	}{
+		{
+			name:     "business hours",
+			env:      controller.RuleEnv{Type: controller.RuleTypeDevice, Time: monday, Hour: 10, Weekday: "Mon"},
+			expected: []string{"business-hours"},
+		},
+		{
+			name: "weekend",
+			env:  controller.RuleEnv{Type: controller.RuleTypeDevice, Time: sunday, Hour: 10, Weekday: "Sun"},
+		},
+		{
+			name:     "metadata",
+			env:      controller.RuleEnv{Type: controller.RuleTypeDevice, Hour: 20, Weekday: "Mon", Metadata: map[string]string{"site": "a"}},
+			expected: []string{"site-a"},
+		},
+		{
+			name: "missing metadata",
+			env:  controller.RuleEnv{Type: controller.RuleTypeDevice, Hour: 20, Weekday: "Mon"},
+		},
+		{
+			name:     "hub online",
+			env:      controller.RuleEnv{Type: controller.RuleTypeDevice, Hour: 20, Weekday: "Mon", Hub: controller.RuleHubEnv{Known: true, Id: "h1", Online: true}},
+			expected: []string{"hub-online"},
+		},
+		{
+			name: "hub offline",
+			env:  controller.RuleEnv{Type: controller.RuleTypeDevice, Hour: 20, Weekday: "Mon", Hub: controller.RuleHubEnv{Known: true, Id: "h1"}},
+		},
+		{
+			name:     "history",
+			env:      controller.RuleEnv{Type: controller.RuleTypeDevice, Connected: true, History: controller.RuleHistoryEnv{Disconnects1h: 4}},
+			expected: []string{"unstable"},
+		},
+		{
+			name:     "previous state duration",
+			env:      controller.RuleEnv{Type: controller.RuleTypeDevice, Connected: true, PreviousStateDuration: 2 * time.Hour},
+			expected: []string{"long-offline"},
+		},
+		{
+			name:     "hub type",
+			env:      controller.RuleEnv{Type: controller.RuleTypeHub, Hour: 20, Weekday: "Mon", Metadata: map[string]string{"site": "a"}},
+			expected: []string{"hubs"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := controller.EvaluateNotificationRules(rules, c.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, c.expected) {
+				t.Error(actual)
+			}
+		})
+	}
+
+	invalid := [][]config.NotificationRule{
+		{{Name: "", Condition: "connected"}},
+		{{Name: "a.b", Condition: "connected"}},
+		{{Name: "a", Condition: "connected"}, {Name: "a", Condition: "connected"}},
+		{{Name: "a", Condition: "unknown_variable"}},
+		{{Name: "a", Condition: "name"}},
+		{{Name: "a", Type: "foo", Condition: "connected"}},
+		{{Name: "a", Condition: "connected", Cooldown: "foo"}},
+	}
+	for _, rules := range invalid {
+		_, err := controller.EvaluateNotificationRules(rules, controller.RuleEnv{})
+		if err == nil {
+			t.Error("expected error", rules)
+		}
+	}
+}
+
+func TestValidateNotificationRuleTemplates(t *testing.T) {
+	templates, err := controller.LoadNotificationTemplates("", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		rules []config.NotificationRule
+		valid bool
+	}{
+		{rules: []config.NotificationRule{{Name: "default"}}, valid: true},
+		{rules: []config.NotificationRule{{Name: "known", Template: controller.DeviceOfflineTemplate}}, valid: true},
+		{rules: []config.NotificationRule{{Name: "default"}, {Name: "typo", Template: "device_ofline"}}, valid: false},
+	}
+	for _, c := range cases {
+		err = controller.ValidateNotificationRuleTemplates(c.rules, templates)
+		if (err == nil) != c.valid {
+			t.Error(c.rules, err)
+		}
+	}
+}
+
+func TestNotificationRules(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.RoundTime = "1m"
+	defaultConfig.InitTopics = true
+	defaultConfig.NotificationRules = []config.NotificationRule{
+		{Name: "Site A Offline", Type: controller.RuleTypeDevice, Condition: `!connected && metadata.site == "a"`, Cooldown: "1h"},
+		{Name: "Unstable", Type: controller.RuleTypeDevice, Condition: `history.disconnects_1h >= 3`, Topic: "unstable", Cooldown: "1h"},
+	}
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	notifications := []string{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		temp, _ := io.ReadAll(r.Body)
+		notifications = append(notifications, strings.TrimSpace(string(temp)))
+	}))
+	defer s.Close()
+	conf.NotificationUrl = s.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker) == 0 {
+		t.Fatal(broker)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	send := func(id string, connected bool, site string) {
+		sendFullDeviceLog(t, producer, model.DeviceLog{
+			Id:          id,
+			Connected:   connected,
+			Time:        time.Now(),
+			DeviceOwner: "owner1",
+			DeviceName:  "device " + id,
+			Metadata:    map[string]string{"site": site},
+		})
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	//site a, notified once because of the cooldown
+	send("d1", false, "a")
+	send("d1", true, "a")
+	send("d1", false, "a")
+	//site b
+	send("d2", false, "b")
+	send("d2", true, "b")
+	send("d2", false, "b")
+	send("d2", true, "b")
+	send("d2", false, "b")
+	time.Sleep(2 * time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	t.Logf("%#v\n", notifications)
+
+	expected := []string{
+		"{\"userId\":\"owner1\",\"title\":\"Site A Offline\",\"message\":\"device device d1 (d1) is offline\",\"topic\":\"notification_rule\"}",
+		"{\"userId\":\"owner1\",\"title\":\"Unstable\",\"message\":\"device device d2 (d2) is offline\",\"topic\":\"unstable\"}",
+	}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ne:%v\na:%v\n", expected, notifications)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"log"
+	"strings"
+	"sync"
+)
+
+func Mosquitto(ctx context.Context, wg *sync.WaitGroup) (brokerUrl string, err error) {
+	log.Println("start mosquitto")
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "eclipse-mosquitto:2.0.18",
+			ExposedPorts: []string{"1883/tcp"},
+			Cmd:          []string{"mosquitto", "-c", "/mosquitto-no-auth.conf"},
+			WaitingFor:   wait.ForListeningPort("1883/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		log.Println("DEBUG: remove container mosquitto", c.Terminate(context.Background()))
+	}()
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+	temp, err := c.MappedPort(ctx, "1883/tcp")
+	if err != nil {
+		return "", err
+	}
+	return "tcp://" + strings.ReplaceAll(host, "localhost", "127.0.0.1") + ":" + temp.Port(), nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// TraceCollector is an in-process OTLP/HTTP trace receiver. Url + "/v1/traces" may be used as config.OtlpTraceUrl.
+type TraceCollector struct {
+	Url   string
+	mux   sync.Mutex
+	spans []*tracepb.Span
+}
+
+func NewTraceCollector(ctx context.Context, wg *sync.WaitGroup) *TraceCollector {
+	result := &TraceCollector{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := &coltracepb.ExportTraceServiceRequest{}
+		err = proto.Unmarshal(body, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result.mux.Lock()
+		for _, resourceSpans := range req.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				result.spans = append(result.spans, scopeSpans.Spans...)
+			}
+		}
+		result.mux.Unlock()
+		resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	})
+	server := httptest.NewServer(mux)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer server.Close()
+		<-ctx.Done()
+	}()
+	result.Url = server.URL
+	return result
+}
+
+func (this *TraceCollector) Spans() []*tracepb.Span {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]*tracepb.Span{}, this.spans...)
+}
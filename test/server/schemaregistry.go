@@ -0,0 +1,73 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/schemaregistry"
+)
+
+// SchemaRegistry is a local stand-in for a Confluent compatible schema registry.
+// it serves the schemas registered with Register.
+type SchemaRegistry struct {
+	Url     string
+	mux     sync.Mutex
+	schemas []schemaregistry.Schema
+}
+
+func NewSchemaRegistry(ctx context.Context, wg *sync.WaitGroup) *SchemaRegistry {
+	result := &SchemaRegistry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /schemas/ids/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result.mux.Lock()
+		defer result.mux.Unlock()
+		if id < 1 || id > len(result.schemas) {
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		json.NewEncoder(w).Encode(result.schemas[id-1])
+	})
+	server := httptest.NewServer(mux)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer server.Close()
+		<-ctx.Done()
+	}()
+	result.Url = server.URL
+	return result
+}
+
+// Register stores schema and returns its id
+func (this *SchemaRegistry) Register(schemaType string, schema string) int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.schemas = append(this.schemas, schemaregistry.Schema{Schema: schema, SchemaType: schemaType})
+	return len(this.schemas)
+}
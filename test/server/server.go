@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"runtime/debug"
+	"strconv"
 	"sync"
 
 	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
@@ -103,5 +104,11 @@ func NewPartial(ctx context.Context, wg *sync.WaitGroup, defaults config.Config)
 	}()
 	config.DeviceRepositoryUrl = server.URL
 
+	apiPort, err := GetFreePort()
+	if err != nil {
+		return config, err
+	}
+	config.ApiPort = strconv.Itoa(apiPort)
+
 	return config, nil
 }
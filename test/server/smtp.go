@@ -0,0 +1,109 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+type Mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// SmtpServer is a minimal smtp stand-in recording received mails
+type SmtpServer struct {
+	Host  string
+	Port  int
+	mux   sync.Mutex
+	mails []Mail
+}
+
+func NewSmtpServer(ctx context.Context, wg *sync.WaitGroup) (*SmtpServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	result := &SmtpServer{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go result.handle(conn)
+		}
+	}()
+	return result, nil
+}
+
+func (this *SmtpServer) Mails() []Mail {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]Mail{}, this.mails...)
+}
+
+func (this *SmtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ESMTP test")
+	mail := Mail{}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			_ = text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail = Mail{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			_ = text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.To = append(mail.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			_ = text.PrintfLine("250 OK")
+		case command == "DATA":
+			_ = text.PrintfLine("354 send data")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			this.mux.Lock()
+			this.mails = append(this.mails, mail)
+			this.mux.Unlock()
+			_ = text.PrintfLine("250 OK %v", len(data))
+		case command == "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
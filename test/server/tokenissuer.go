@@ -0,0 +1,109 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TokenIssuer is a minimal oauth2 token endpoint stand-in for the client-credentials grant
+type TokenIssuer struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	ExpiresIn    int //seconds
+	mux          sync.Mutex
+	issued       []string
+}
+
+func NewTokenIssuer(ctx context.Context, wg *sync.WaitGroup, clientId string, clientSecret string, expiresIn int) *TokenIssuer {
+	result := &TokenIssuer{ClientId: clientId, ClientSecret: clientSecret, ExpiresIn: expiresIn}
+	server := httptest.NewServer(http.HandlerFunc(result.handle))
+	result.TokenUrl = server.URL + "/token"
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		server.Close()
+	}()
+	return result
+}
+
+func (this *TokenIssuer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/token" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeTokenError(w, "unsupported_grant_type")
+		return
+	}
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientId != this.ClientId || clientSecret != this.ClientSecret {
+		writeTokenError(w, "invalid_client")
+		return
+	}
+	this.mux.Lock()
+	token := "token-" + strconv.Itoa(len(this.issued)+1)
+	this.issued = append(this.issued, token)
+	this.mux.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   this.ExpiresIn,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// Issued returns the number of issued tokens
+func (this *TokenIssuer) Issued() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.issued)
+}
+
+// Valid checks an Authorization header value against the issued tokens
+func (this *TokenIssuer) Valid(authorization string) bool {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return false
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, issued := range this.issued {
+		if issued == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"github.com/SENERGY-Platform/connection-log-worker/lib/controller"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotificationTemplates(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "fr"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(dir, "en"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "fr", "device_offline.tmpl"), []byte(`{{define "title"}}Appareil hors ligne{{end}}
+{{define "message"}}l'appareil {{.DeviceName}} ({{.DeviceId}}) de la passerelle {{.HubName}} est hors ligne depuis {{.OfflineSince.UTC.Format "15:04"}} ({{.Duration}}){{end}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "en", "device_online.tmpl"), []byte(`{{define "title"}}Online{{end}}
+{{define "message"}}{{.DeviceName}} on {{index .Metadata "site"}} is online{{end}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := controller.LoadNotificationTemplates(dir, "en")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	data := controller.NotificationTemplateData{
+		DeviceId:     "d1",
+		DeviceName:   "device 1",
+		HubName:      "hub 1",
+		Duration:     "10m0s",
+		OfflineSince: time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC),
+		Metadata:     map[string]string{"site": "a"},
+	}
+
+	cases := []struct {
+		name            string
+		locale          string
+		data            controller.NotificationTemplateData
+		expectedTitle   string
+		expectedMessage string
+	}{
+		{name: controller.DeviceOfflineTemplate, locale: "en", data: data, expectedTitle: "Device Offline", expectedMessage: "device device 1 (d1) has been offline for 10m0s"},
+		{name: controller.DeviceOfflineTemplate, locale: "", data: data, expectedTitle: "Device Offline", expectedMessage: "device device 1 (d1) has been offline for 10m0s"},
+		{name: controller.DeviceOfflineTemplate, locale: "de_DE", data: data, expectedTitle: "Gerät offline", expectedMessage: "Gerät device 1 (d1) ist seit 10m0s offline"},
+		{name: controller.DeviceOfflineTemplate, locale: "fr-CA", data: data, expectedTitle: "Appareil hors ligne", expectedMessage: "l'appareil device 1 (d1) de la passerelle hub 1 est hors ligne depuis 12:30 (10m0s)"},
+		{name: controller.DeviceOnlineTemplate, locale: "fr", data: data, expectedTitle: "Online", expectedMessage: "device 1 on a is online"},
+		{name: controller.DeviceOnlineTemplate, locale: "de", data: data, expectedTitle: "Gerät wieder online", expectedMessage: "Gerät device 1 (d1) ist nach 10m0s wieder online"},
+		{name: controller.HubOfflineTemplate, locale: "unknown", data: controller.NotificationTemplateData{HubId: "h1", HubName: "hub 1", Duration: "1h0m0s", Severity: "critical", UnreachableDevices: 2, Devices: 3}, expectedTitle: "Gateway Offline (critical)", expectedMessage: "gateway hub 1 (h1) has been offline for 1h0m0s; 2 of its 3 devices are unreachable"},
+	}
+	for _, c := range cases {
+		title, message, err := templates.Render(c.name, c.locale, c.data)
+		if err != nil {
+			t.Error(c.name, c.locale, err)
+			continue
+		}
+		if title != c.expectedTitle || message != c.expectedMessage {
+			t.Errorf("%v %v\n%v\n%v\n%v\n%v\n", c.name, c.locale, c.expectedTitle, title, c.expectedMessage, message)
+		}
+	}
+
+	title, message, err := templates.Render(controller.DeviceOfflineDigestTemplate, "en", controller.NotificationTemplateData{
+		Devices: 3,
+		Entries: []controller.NotificationTemplateData{{DeviceId: "d1", DeviceName: "device 1", Duration: "10m0s"}},
+		More:    2,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if title != "3 Devices Offline" || message != "3 devices have gone offline:\n- device 1 (d1) offline for 10m0s\nand 2 more" {
+		t.Errorf("%#v %#v", title, message)
+	}
+
+	_, _, err = templates.Render("unknown", "en", data)
+	if err == nil {
+		t.Error("expected error for unknown template")
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "en", "device_offline.tmpl"), []byte(`{{define "title"}}only title{{end}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = controller.LoadNotificationTemplates(dir, "en")
+	if err == nil {
+		t.Error("expected error for missing message template")
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/SENERGY-Platform/connection-log-worker/lib"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/config"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/model"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/consumer"
+	"github.com/SENERGY-Platform/connection-log-worker/lib/source/util"
+	"github.com/SENERGY-Platform/connection-log-worker/test/helper"
+	"github.com/SENERGY-Platform/connection-log-worker/test/server"
+	"github.com/segmentio/kafka-go"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracing(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defaultConfig, err := config.Load("../config.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defaultConfig.Debug = true
+	defaultConfig.InitTopics = true
+
+	conf, err := server.NewPartial(ctx, wg, defaultConfig)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.InitTopics = true
+
+	collector := server.NewTraceCollector(ctx, wg)
+	conf.OtlpTraceUrl = collector.Url + "/v1/traces"
+
+	mux := sync.Mutex{}
+	notifierTraceParents := []string{}
+	notifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		notifierTraceParents = append(notifierTraceParents, r.Header.Get("traceparent"))
+	}))
+	defer notifier.Close()
+	conf.NotificationUrl = notifier.URL
+
+	err = lib.Start(ctx, conf, func(err error, consumer *consumer.Consumer) {
+		t.Error(err)
+		return
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := util.GetBroker(conf.KafkaUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := helper.GetProducer(broker, conf.DeviceLogTopic, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceParent := "00-" + traceId + "-00f067aa0ba902b7-01"
+	sendTracedDeviceLog := func(deviceLog model.DeviceLog) {
+		b, err := json.Marshal(deviceLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = producer.WriteMessages(context.Background(), kafka.Message{
+			Key:     []byte(deviceLog.Id),
+			Value:   b,
+			Time:    time.Now(),
+			Headers: []kafka.Header{{Key: "traceparent", Value: []byte(traceParent)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deviceLog := model.DeviceLog{
+		Id:                     "traced",
+		Connected:              false,
+		Time:                   time.Now(),
+		MonitorConnectionState: "1s",
+		DeviceOwner:            "testowner",
+		DeviceName:             "traced device",
+	}
+	sendTracedDeviceLog(deviceLog)
+	time.Sleep(2 * time.Second)
+	deviceLog.Time = time.Now()
+	sendTracedDeviceLog(deviceLog)
+	time.Sleep(5 * time.Second)
+
+	spanNames := map[string]bool{}
+	for _, span := range collector.Spans() {
+		if hex.EncodeToString(span.TraceId) == traceId {
+			spanNames[span.Name] = true
+		}
+	}
+	for _, expected := range []string{
+		conf.DeviceLogTopic + " process",
+		"device-repository SetDeviceConnectionState",
+		"mongodb setDeviceState",
+		"mongodb getDeviceOfflineNotificationInfos",
+		"influxdb logDeviceHistory",
+		"notifier POST /notifications",
+	} {
+		if !spanNames[expected] {
+			t.Errorf("missing span %v in %#v", expected, spanNames)
+		}
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(notifierTraceParents) != 1 || !strings.Contains(notifierTraceParents[0], traceId) {
+		t.Errorf("%#v", notifierTraceParents)
+	}
+}